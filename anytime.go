@@ -0,0 +1,130 @@
+package vptree
+
+import (
+	"container/heap"
+	"context"
+	"math"
+)
+
+// anytimeBatch is the number of nodes SearchAnytime visits between sending
+// result sets and checking for cancellation.
+const anytimeBatch = 64
+
+// SearchAnytime searches the VP-tree for the k nearest neighbours of target
+// in the background and sends successively better result sets on the returned
+// channel, each in order of least distance to largest distance. The channel
+// is closed after the exact result set has been sent, or once ctx is done.
+//
+// The search visits the subtrees in order of how close they could be to the
+// target, so the first result sets are already good approximations. The
+// channel only holds the latest result set: if the receiver is slower than
+// the search, outdated sets are dropped.
+func (vp *VPTree) SearchAnytime(ctx context.Context, target interface{}, k int) <-chan []Neighbor {
+	ch := make(chan []Neighbor, 1)
+
+	go func() {
+		defer close(ch)
+		if k < 1 {
+			return
+		}
+
+		send := func(set []Neighbor) {
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- set
+		}
+
+		s := vp.newSearcher(target, SearchParameters{K: k})
+
+		improved, visited := false, 0
+		s.searchBestFirst(vp.root, func(accepted bool) bool {
+			improved = improved || accepted
+			visited++
+			if visited%anytimeBatch != 0 {
+				return true
+			}
+
+			if ctx.Err() != nil {
+				return false
+			}
+			if improved {
+				send(neighbors(s.h))
+				improved = false
+			}
+			return true
+		})
+
+		if ctx.Err() == nil {
+			send(neighbors(s.h))
+		}
+	}()
+
+	return ch
+}
+
+// searchBestFirst searches the subtree rooted at n like search, but visits
+// the subtrees in order of their lower bounds instead of depth-first, so that
+// it finds good results early. If each is set, it is called after every
+// visited node with whether the node's item was added to the results, and
+// the search stops if it returns false.
+func (s *searcher) searchBestFirst(n *node, each func(accepted bool) bool) {
+	// The queue holds subtrees with their negated lower bounds, so that its
+	// top is the subtree that could be closest.
+	var queue priorityQueue
+	if n != nil {
+		heap.Push(&queue, &heapItem{node: n})
+	}
+
+	for queue.Len() > 0 {
+		hi := heap.Pop(&queue).(*heapItem)
+		n, lower := hi.node, -hi.Dist
+		if lower > s.tau {
+			// No remaining subtree can improve the results
+			s.prune(lower)
+			return
+		}
+
+		if s.p.MaxNodes > 0 && s.stats.NodesVisited >= s.p.MaxNodes {
+			s.truncated = true
+			s.prune(lower)
+			return
+		}
+
+		s.stats.NodesVisited++
+		s.stats.MetricCalls++
+		dist := s.vp.distanceMetric(n.Item, s.target)
+
+		accepted := s.add(n, dist)
+
+		for _, b := range n.Bucket {
+			heap.Push(&queue, &heapItem{node: b, Dist: -lower})
+		}
+		if n.Left != nil {
+			leftLower := math.Max(lower, dist-math.Min(s.vp.leftBound(n), n.LeftRadius))
+			heap.Push(&queue, &heapItem{node: n.Left, Dist: -leftLower})
+		}
+		if n.Right != nil {
+			rightLower := math.Max(lower, math.Max(n.Threshold-dist, dist-n.RightRadius))
+			heap.Push(&queue, &heapItem{node: n.Right, Dist: -rightLower})
+		}
+
+		if each != nil && !each(accepted) {
+			return
+		}
+	}
+}
+
+// neighbors returns the contents of the heap h in order of least distance to
+// largest distance, leaving h unchanged.
+func neighbors(h priorityQueue) []Neighbor {
+	h = append(priorityQueue(nil), h...)
+
+	ns := make([]Neighbor, h.Len())
+	for i := len(ns) - 1; i >= 0; i-- {
+		hi := heap.Pop(&h).(*heapItem)
+		ns[i] = Neighbor{hi.Item, hi.Dist}
+	}
+	return ns
+}
@@ -0,0 +1,58 @@
+package vptree
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchAnytime sends improving result sets and ends
+// with the exact k nearest neighbours
+func TestSearchAnytime(t *testing.T) {
+	vp := newRandomCoordinateTree(20000)
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		var sets [][]Neighbor
+		for set := range vp.SearchAnytime(context.Background(), q, 10) {
+			sets = append(sets, set)
+		}
+		if len(sets) == 0 {
+			t.Fatal("Expected at least one result set")
+		}
+
+		for j := 1; j < len(sets); j++ {
+			prev, cur := sets[j-1], sets[j]
+			if len(prev) == len(cur) && cur[len(cur)-1].Distance > prev[len(prev)-1].Distance {
+				t.Errorf("Expected result sets to improve, but the worst distance grew from %v to %v", prev[len(prev)-1].Distance, cur[len(cur)-1].Distance)
+			}
+		}
+
+		expected, expectedDists := vp.Search(q, 10)
+		final := sets[len(sets)-1]
+		var results []interface{}
+		var distances []float64
+		for _, n := range final {
+			results = append(results, n.Item)
+			distances = append(distances, n.Distance)
+		}
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+}
+
+// This test makes sure SearchAnytime stops once its context is canceled
+func TestSearchAnytimeCancel(t *testing.T) {
+	vp := newRandomCoordinateTree(20000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, ok := <-vp.SearchAnytime(ctx, Coordinate{}, 10); ok {
+		t.Error("Expected no result sets after the context was canceled")
+	}
+
+	if _, ok := <-vp.SearchAnytime(context.Background(), Coordinate{}, 0); ok {
+		t.Error("Expected no result sets for k = 0")
+	}
+}
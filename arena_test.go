@@ -0,0 +1,53 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure a tree built in an arena searches correctly
+func TestNewWithArena(t *testing.T) {
+	items := make([]interface{}, 5000)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := NewWithArena(CoordinateMetric, append([]interface{}(nil), items...))
+
+	if err := vp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		results, distances := vp.Search(q, 10)
+		expected, expectedDists := BruteForceSearch(CoordinateMetric, items, q, 10)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+
+	// Inserts allocate new nodes once the arena is used up
+	c := Coordinate{X: 2, Y: 2}
+	vp.Insert(c)
+	if results, _ := vp.Search(c, 1); results[0] != c {
+		t.Errorf("Expected to find the inserted item, got %v", results[0])
+	}
+}
+
+func benchmarkBuild(b *testing.B, build func(metric Metric, items []interface{}) *VPTree) {
+	items := uniformVectors(rand.New(rand.NewSource(1)), 100000, 4)
+	buf := make([]interface{}, len(items))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(buf, items)
+		build(VectorMetric, buf)
+	}
+}
+
+func BenchmarkBuildDefault(b *testing.B) {
+	benchmarkBuild(b, New)
+}
+
+func BenchmarkBuildArena(b *testing.B) {
+	benchmarkBuild(b, NewWithArena)
+}
@@ -0,0 +1,130 @@
+package vptree
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// autoSamples is the number of random pairs of items the cost model of
+	// AutoSearch measures.
+	autoSamples = 256
+
+	// autoOverhead is the factor by which visiting a node is assumed to be
+	// slower than evaluating the metric.
+	autoOverhead = 2
+)
+
+// autoModel is the cost model of AutoSearch. It is measured once per tree,
+// the first time it is needed.
+type autoModel struct {
+	once sync.Once
+
+	// dimension is the estimated intrinsic dimension of the items.
+	dimension float64
+
+	// nodeCost is the estimated time it takes to visit a node.
+	nodeCost time.Duration
+}
+
+// model returns the cost model of the VP-tree, measuring it if necessary.
+func (vp *VPTree) model() *autoModel {
+	m := vp.auto
+	m.once.Do(func() {
+		if vp.root == nil || vp.root.Size < 2 {
+			m.dimension = 1
+			return
+		}
+
+		pairs := make([][2]interface{}, autoSamples)
+		for i := range pairs {
+			pairs[i] = [2]interface{}{randomItem(vp.root, vp.rnd), randomItem(vp.root, vp.rnd)}
+		}
+
+		dists := make([]float64, len(pairs))
+		start := time.Now()
+		for i, p := range pairs {
+			dists[i] = vp.distanceMetric(p[0], p[1])
+		}
+		m.nodeCost = autoOverhead * time.Since(start) / time.Duration(len(pairs))
+
+		// The intrinsic dimension of Chávez et al.: the more concentrated
+		// the distances are around their mean, the higher the dimension.
+		var sum, sumSq float64
+		for _, d := range dists {
+			sum += d
+			sumSq += d * d
+		}
+		mean := sum / float64(len(dists))
+		variance := sumSq/float64(len(dists)) - mean*mean
+
+		m.dimension = math.Inf(1)
+		if variance > 0 {
+			m.dimension = math.Max(1, mean*mean/(2*variance))
+		}
+	})
+	return m
+}
+
+// IntrinsicDimension estimates the intrinsic dimension of the items in the
+// VP-tree as mu^2 / (2 sigma^2), where mu and sigma^2 are the mean and
+// variance of the distances between random pairs of items. It is about the
+// number of dimensions for uniformly distributed vectors, but also applies to
+// data without coordinates. High intrinsic dimensions make exact searches
+// expensive, because distances are too similar to prune much.
+//
+// The estimate is computed the first time it is needed and then reused.
+func (vp *VPTree) IntrinsicDimension() float64 {
+	return vp.model().dimension
+}
+
+// AutoSearch searches the VP-tree for the k nearest neighbours of target,
+// choosing between an exact and an approximate search so that the search is
+// expected to take at most latencyBudget.
+//
+// The decision is based on a cost model that is measured once per tree: the
+// time it takes to visit a node, and the intrinsic dimension d of the items
+// (see IntrinsicDimension). An exact search on n items is expected to visit
+// about n^(1-1/d) nodes, which grows from sqrt(n) nodes in two dimensions to
+// almost all nodes in high dimensions. If visiting that many nodes fits into
+// the budget, AutoSearch searches exactly. Otherwise it visits as many nodes
+// as fit into the budget (but at least k), in best-first order, so that the
+// results are as good as possible for the time spent.
+func (vp *VPTree) AutoSearch(target interface{}, k int, latencyBudget time.Duration) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	s := vp.newSearcher(target, SearchParameters{K: k})
+	if exact, maxNodes := vp.autoMode(k, latencyBudget); exact {
+		s.run()
+	} else {
+		s.p.MaxNodes = maxNodes
+		s.searchBestFirst(vp.root, nil)
+	}
+
+	return s.results()
+}
+
+// autoMode decides whether AutoSearch searches exactly, and if not, how many
+// nodes it visits.
+func (vp *VPTree) autoMode(k int, latencyBudget time.Duration) (exact bool, maxNodes int) {
+	if vp.root == nil {
+		return true, 0
+	}
+
+	m := vp.model()
+	n := float64(vp.root.Size)
+	expected := math.Pow(n, 1-1/m.dimension)
+
+	if m.nodeCost <= 0 || time.Duration(expected*float64(m.nodeCost)) <= latencyBudget {
+		return true, 0
+	}
+
+	maxNodes = int(latencyBudget / m.nodeCost)
+	if maxNodes < k {
+		maxNodes = k
+	}
+	return false, maxNodes
+}
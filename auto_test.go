@@ -0,0 +1,52 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// This test makes sure AutoSearch searches exactly on low-dimensional data
+// and approximately on high-dimensional data under a tight budget
+func TestAutoSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	low := New(VectorMetric, uniformVectors(r, 20000, 2))
+	if d := low.IntrinsicDimension(); d < 1 || d > 5 {
+		t.Errorf("Expected an intrinsic dimension of about 2, got %v", d)
+	}
+	if exact, _ := low.autoMode(10, 10*time.Millisecond); !exact {
+		t.Error("Expected an exact search on low-dimensional data")
+	}
+
+	for i := 0; i < 10; i++ {
+		q := uniformVectors(r, 1, 2)[0]
+		_, expectedDists := low.Search(q, 10)
+		_, distances := low.AutoSearch(q, 10, 10*time.Millisecond)
+		if len(distances) != len(expectedDists) {
+			t.Fatalf("Expected %v results, got %v", len(expectedDists), len(distances))
+		}
+		for j := range distances {
+			if distances[j] != expectedDists[j] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, expectedDists[j], distances[j])
+			}
+		}
+	}
+
+	high := New(VectorMetric, uniformVectors(r, 20000, 64))
+	if d := high.IntrinsicDimension(); d < 20 {
+		t.Errorf("Expected a high intrinsic dimension, got %v", d)
+	}
+	exact, maxNodes := high.autoMode(10, 20*time.Microsecond)
+	if exact {
+		t.Error("Expected an approximate search on high-dimensional data under a tight budget")
+	}
+	if maxNodes < 10 || maxNodes >= 20000 {
+		t.Errorf("Expected a node budget between 10 and 20000, got %v", maxNodes)
+	}
+
+	results, _ := high.AutoSearch(uniformVectors(r, 1, 64)[0], 10, 20*time.Microsecond)
+	if len(results) != 10 {
+		t.Errorf("Expected 10 results, got %v", len(results))
+	}
+}
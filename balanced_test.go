@@ -0,0 +1,92 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func absMetric(a, b interface{}) float64 {
+	return math.Abs(a.(float64) - b.(float64))
+}
+
+// countNodes returns the number of nodes in the subtree rooted at n
+func countNodes(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return 1 + countNodes(n.Left) + countNodes(n.Right)
+}
+
+// This test builds a tree over items with many duplicate distances and makes
+// sure no two siblings differ in size by more than the balance factor
+func TestBalancedFactor(t *testing.T) {
+	const factor = 2.0
+
+	items := make([]float64, 1000)
+	vpitems := make([]interface{}, len(items))
+	for i := range items {
+		items[i] = float64(rand.Intn(5))
+		vpitems[i] = items[i]
+	}
+
+	vp := NewBalancedFactor(absMetric, vpitems, factor)
+
+	var check func(n *node)
+	check = func(n *node) {
+		if n == nil {
+			return
+		}
+
+		l, r := countNodes(n.Left), countNodes(n.Right)
+		small, large := math.Min(float64(l), float64(r)), math.Max(float64(l), float64(r))
+		if large-small > 1 && large > factor*small {
+			t.Fatalf("Sibling subtrees of sizes %v and %v exceed balance factor %v", l, r, factor)
+		}
+
+		check(n.Left)
+		check(n.Right)
+	}
+	check(vp.root)
+
+	if n := countNodes(vp.root); n != len(items) {
+		t.Fatalf("Expected %v nodes, got %v", len(items), n)
+	}
+
+	// Searching still has to work correctly
+	for q := 0.0; q < 5; q += 0.5 {
+		results, distances := vp.Search(q, 10)
+		if len(results) != 10 {
+			t.Fatalf("Expected 10 results, got %v", len(results))
+		}
+		for i, d := range distances {
+			if d != absMetric(q, results[i]) {
+				t.Errorf("Expected distance %v for %v, got %v", absMetric(q, results[i]), results[i], d)
+			}
+			if d > math.Abs(q-math.Round(q)) {
+				t.Errorf("Expected every result to be a closest value, got distance %v", d)
+			}
+		}
+	}
+}
+
+// This test makes sure the balanced build returns the same results as a
+// brute-force search on random data
+func TestBalancedFactorRandom(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := NewBalancedFactor(CoordinateMetric, vpitems, 1.5)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	coords1, distances1 := vp.Search(q, 20)
+	coords2, distances2 := nearestNeighbours(q, items, 20)
+
+	compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+}
@@ -0,0 +1,50 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Flatten returns all items of the VP-tree in a freshly allocated slice. It is
+// meant as the input for BruteForceSearch when the tree does not help, e.g.
+// because the intrinsic dimension of the data is so high that searches visit
+// almost every node anyway: a linear scan then avoids the overhead of the
+// tree without the items having to be extracted again.
+func (vp *VPTree) Flatten() []interface{} {
+	return vp.Items()
+}
+
+// BruteForceSearch finds the k nearest neighbours of target among items by
+// measuring the distance to every item. It returns the up to k nearest
+// neighbours and the corresponding distances in order of least distance to
+// largest distance, just like VPTree.Search.
+func BruteForceSearch(metric Metric, items []interface{}, target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	h := make(priorityQueue, 0, k)
+	tau := math.MaxFloat64
+
+	for _, item := range items {
+		dist := metric(item, target)
+		if dist < tau || (h.Len() < k && dist <= tau) {
+			if h.Len() == k {
+				heap.Pop(&h)
+			}
+			heap.Push(&h, &heapItem{Item: item, Dist: dist})
+			if h.Len() == k {
+				tau = h.Top().(*heapItem).Dist
+			}
+		}
+	}
+
+	results = make([]interface{}, h.Len())
+	distances = make([]float64, h.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		hi := heap.Pop(&h).(*heapItem)
+		results[i], distances[i] = hi.Item, hi.Dist
+	}
+
+	return
+}
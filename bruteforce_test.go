@@ -0,0 +1,55 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure Flatten returns exactly the items of the tree
+func TestFlatten(t *testing.T) {
+	vpitems := make([]interface{}, 500)
+	counts := make(map[interface{}]int)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: float64(rand.Intn(50)), Y: float64(rand.Intn(50))}
+		counts[vpitems[i]]++
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), vpitems...))
+
+	flat := vp.Flatten()
+	if len(flat) != len(vpitems) {
+		t.Fatalf("Expected %v items, got %v", len(vpitems), len(flat))
+	}
+	for _, item := range flat {
+		counts[item]--
+	}
+	for item, count := range counts {
+		if count != 0 {
+			t.Errorf("Expected %v to appear %v more times in the flattened items", item, count)
+		}
+	}
+
+	// Modifying the flattened items must not affect the tree
+	flat[0] = Coordinate{-1, -1}
+	if results, _ := vp.Search(Coordinate{-1, -1}, 1); results[0] == flat[0] {
+		t.Error("Expected the tree to be unaffected by changes to the flattened items")
+	}
+}
+
+// This test compares BruteForceSearch on the flattened items against Search
+func TestBruteForceSearch(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	flat := vp.Flatten()
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(30) + 1
+
+		expected, expectedDists := vp.Search(q, k)
+		results, distances := BruteForceSearch(CoordinateMetric, flat, q, k)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+
+	if results, _ := BruteForceSearch(CoordinateMetric, flat, Coordinate{}, 0); len(results) != 0 {
+		t.Errorf("Expected no results for k = 0, got %v", results)
+	}
+}
@@ -0,0 +1,79 @@
+package vptree
+
+import "sort"
+
+// SearchBatchBudget searches for the nearest neighbours of every target, but
+// visits at most totalNodeBudget nodes across the whole batch. The budget is
+// handed out in order: each target may use an equal share of the budget that
+// is left, so queries that finish early leave more for the following ones,
+// and hard queries are cut off with the best results found so far. Any
+// MaxNodes set in p is overridden.
+func (vp *VPTree) SearchBatchBudget(targets []interface{}, p SearchParameters, totalNodeBudget int) (results [][]interface{}, distances [][]float64) {
+	results = make([][]interface{}, len(targets))
+	distances = make([][]float64, len(targets))
+
+	remaining := totalNodeBudget
+	for i, target := range targets {
+		share := remaining / (len(targets) - i)
+		if share < 1 {
+			continue
+		}
+
+		p.MaxNodes = share
+		var stats SearchStats
+		results[i], distances[i], stats = vp.SearchWithStats(target, p)
+		remaining -= stats.NodesVisited
+	}
+
+	return
+}
+
+// NewWithMetricBudget is like New, but makes at most maxCalls metric
+// evaluations while building the tree. If building from all items would take
+// more, it builds from the largest random sample of items that fits the
+// budget instead, and leaves the other items out; they can still be added
+// later with Insert, which costs a few metric evaluations per item. It returns
+// the tree and the number of items it indexed.
+//
+// To know the cost of a build in advance, NewWithMetricBudget splits every
+// node exactly in half, like NewBalancedFactor with a factor of 1. Building
+// from n items then takes about n*log2(n) metric evaluations, so that a budget
+// of a million calls is enough for about 60,000 items. Like New,
+// NewWithMetricBudget reorders the items slice.
+func NewWithMetricBudget(metric Metric, items []interface{}, maxCalls int) (t *VPTree, indexed int) {
+	costs := make(map[int]int)
+	indexed = sort.Search(len(items)+1, func(n int) bool {
+		return buildCost(n, costs) > maxCalls
+	}) - 1
+
+	t = newVPTree(metric, indexed)
+	t.balanceFactor = 1
+	if indexed < len(items) {
+		// Move a random sample to the front of the items
+		for i := 0; i < indexed; i++ {
+			j := i + t.rnd.Intn(len(items)-i)
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+	t.root = t.buildFromPoints(items[:indexed], 0)
+
+	return t, indexed
+}
+
+// buildCost returns the number of metric evaluations it takes to build a tree
+// with a balance factor of 1 from n items: every node measures all other items
+// of its subtree against its vantage point, and splits them in half. costs
+// memoizes the results.
+func buildCost(n int, costs map[int]int) int {
+	if n <= 1 {
+		return 0
+	}
+	if c, ok := costs[n]; ok {
+		return c
+	}
+
+	rest := n - 1
+	c := rest + buildCost(rest/2, costs) + buildCost(rest-rest/2, costs)
+	costs[n] = c
+	return c
+}
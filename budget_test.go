@@ -0,0 +1,96 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure a batch of searches stays within its node budget
+func TestSearchBatchBudget(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	calls := 0
+	metric := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := New(metric, vpitems)
+
+	targets := make([]interface{}, 20)
+	for i := range targets {
+		targets[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	for _, budget := range []int{0, 10, 100, 500} {
+		calls = 0
+		results, _ := vp.SearchBatchBudget(targets, SearchParameters{K: 5}, budget)
+
+		if calls > budget {
+			t.Errorf("Expected at most %v nodes to be visited, got %v", budget, calls)
+		}
+		if len(results) != len(targets) {
+			t.Errorf("Expected %v result sets, got %v", len(targets), len(results))
+		}
+	}
+
+	// With a large enough budget, the results are exact
+	results, distances := vp.SearchBatchBudget(targets, SearchParameters{K: 5}, len(targets)*len(items))
+	for i, target := range targets {
+		coords, dists := nearestNeighbours(target.(Coordinate), items, 5)
+		compareCoordDistSets(t, results[i], coords, distances[i], dists)
+	}
+}
+
+// This test makes sure a build stays within its metric budget, and indexes a
+// sample of the items if it has to
+func TestNewWithMetricBudget(t *testing.T) {
+	items := randomCoordinates(1000)
+
+	calls := 0
+	metric := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+
+	for _, maxCalls := range []int{0, 10, 1000, 5000, 100000} {
+		calls = 0
+		vp, indexed := NewWithMetricBudget(metric, append([]interface{}(nil), items...), maxCalls)
+		if calls > maxCalls {
+			t.Errorf("budget %v: expected at most %v metric calls, got %v", maxCalls, maxCalls, calls)
+		}
+		if indexed != vp.size || indexed != len(vp.Items()) {
+			t.Errorf("budget %v: reported %v indexed items, but the tree has %v", maxCalls, indexed, len(vp.Items()))
+		}
+		if err := vp.Validate(); err != nil {
+			t.Errorf("budget %v: %v", maxCalls, err)
+		}
+
+		switch {
+		case maxCalls >= 100000:
+			if indexed != len(items) {
+				t.Errorf("budget %v: expected all %v items to be indexed, got %v", maxCalls, len(items), indexed)
+			}
+		case maxCalls >= 1000:
+			if indexed < 100 || indexed >= len(items) {
+				t.Errorf("budget %v: expected a sample to be indexed, got %v items", maxCalls, indexed)
+			}
+		}
+
+		// A larger sample would not have fit
+		if indexed < len(items) {
+			calls = 0
+			NewBalancedFactor(metric, append([]interface{}(nil), items[:indexed+1]...), 1)
+			if calls <= maxCalls {
+				t.Errorf("budget %v: expected %v items not to fit, but they took %v calls", maxCalls, indexed+1, calls)
+			}
+		}
+	}
+}
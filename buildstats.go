@@ -0,0 +1,71 @@
+package vptree
+
+import "time"
+
+// BuildStats describes where the time was spent while building a VP-tree.
+type BuildStats struct {
+	// Duration is the wall-clock time the whole build took.
+	Duration time.Duration
+
+	// LevelDurations[d] is the time spent choosing vantage points and
+	// partitioning items at depth d of the tree, not counting the time spent
+	// building deeper levels.
+	LevelDurations []time.Duration
+}
+
+// NewWithBuildStats is like New, but also reports how long building each
+// level of the tree took. The deepest levels contain the most nodes, but the
+// top levels partition the most items, so this shows which part of the build
+// dominates for a given dataset.
+func NewWithBuildStats(metric Metric, items []interface{}) (t *VPTree, stats BuildStats) {
+	t = newVPTree(metric, len(items))
+	t.buildStats = &stats
+
+	start := time.Now()
+	t.root = t.buildFromPoints(items, 0)
+	stats.Duration = time.Since(start)
+
+	t.buildStats = nil
+	return
+}
+
+// A BuildEvent describes a node of a VP-tree that has just been built.
+type BuildEvent struct {
+	// Depth is the depth of the node; the root is at depth 0.
+	Depth int
+
+	// Vantage is the item chosen as the vantage point of the node.
+	Vantage interface{}
+
+	// Size is the number of items in the subtree of the node, including
+	// the vantage point.
+	Size int
+
+	// Threshold is the distance from the vantage point that separates the
+	// left subtree from the right one.
+	Threshold float64
+
+	// LeftSize and RightSize are the numbers of items partitioned into the
+	// left and right subtree.
+	LeftSize, RightSize int
+}
+
+// NewWithBuildEvents is like New, but calls onEvent for every node as soon as
+// its vantage point has been chosen and its items partitioned, parents before
+// their children, e.g. to feed build telemetry into logs or metrics. onEvent
+// is called from the goroutine that builds the tree and should return
+// quickly, since it delays the build.
+func NewWithBuildEvents(metric Metric, items []interface{}, onEvent func(BuildEvent)) (t *VPTree) {
+	t = newVPTree(metric, len(items))
+	t.onBuildEvent = onEvent
+	t.root = t.buildFromPoints(items, 0)
+	t.onBuildEvent = nil
+	return
+}
+
+func (s *BuildStats) addLevelTime(depth int, d time.Duration) {
+	for len(s.LevelDurations) <= depth {
+		s.LevelDurations = append(s.LevelDurations, 0)
+	}
+	s.LevelDurations[depth] += d
+}
@@ -0,0 +1,90 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// This test makes sure the per-level build times roughly add up to the total
+// build time
+func TestBuildStats(t *testing.T) {
+	vpitems := make([]interface{}, 20000)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	vp, stats := NewWithBuildStats(CoordinateMetric, vpitems)
+
+	if len(vp.Items()) != len(vpitems) {
+		t.Fatalf("Expected %v items in the tree, got %v", len(vpitems), len(vp.Items()))
+	}
+
+	if len(stats.LevelDurations) == 0 {
+		t.Fatal("Expected at least one level duration")
+	}
+
+	var sum time.Duration
+	for _, d := range stats.LevelDurations {
+		sum += d
+	}
+
+	if sum > stats.Duration {
+		t.Errorf("Level durations add up to %v, which exceeds the total build time %v", sum, stats.Duration)
+	}
+	if sum < stats.Duration/2 {
+		t.Errorf("Level durations add up to %v, which is far less than the total build time %v", sum, stats.Duration)
+	}
+}
+
+// This test makes sure the build events describe the nodes of the tree that
+// was built, in pre-order
+func TestBuildEvents(t *testing.T) {
+	items := make([]interface{}, 7)
+	for i := range items {
+		items[i] = float64(i * i)
+	}
+
+	var events []BuildEvent
+	vp := NewWithBuildEvents(absMetric, items, func(e BuildEvent) {
+		events = append(events, e)
+	})
+
+	size := func(n *node) int {
+		if n == nil {
+			return 0
+		}
+		return n.Size
+	}
+
+	var expected []BuildEvent
+	var visit func(n *node, depth int)
+	visit = func(n *node, depth int) {
+		if n == nil {
+			return
+		}
+		expected = append(expected, BuildEvent{depth, n.Item, n.Size, n.Threshold, size(n.Left), size(n.Right)})
+		visit(n.Left, depth+1)
+		visit(n.Right, depth+1)
+	}
+	visit(vp.root, 0)
+
+	if len(events) != len(items) || len(expected) != len(items) {
+		t.Fatalf("expected %v events, got %v", len(items), events)
+	}
+	if events[0].Depth != 0 || events[0].Size != 7 || events[0].LeftSize+events[0].RightSize != 6 {
+		t.Errorf("expected the root to partition 6 items, got %+v", events[0])
+	}
+	for i := range events {
+		if events[i] != expected[i] {
+			t.Errorf("expected event %v to be %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+
+	// Later builds do not emit events
+	events = nil
+	vp.Rebuild()
+	if len(events) != 0 {
+		t.Errorf("expected no events from Rebuild, got %v", events)
+	}
+}
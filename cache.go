@@ -0,0 +1,200 @@
+package vptree
+
+import (
+	"container/list"
+	"runtime"
+	"sync"
+)
+
+// A CachingVPTree wraps a VPTree and caches the results of recent searches,
+// so that repeated queries do not have to traverse the tree again. It is safe
+// for concurrent use as long as the wrapped tree is not modified.
+type CachingVPTree struct {
+	// Quantize, if set, maps a target to the key its results are cached
+	// under. Targets that are quantized to the same key share a cache entry,
+	// so a query may be answered with the results of an earlier, slightly
+	// different target. This trades accuracy for a higher hit rate when many
+	// queries are small perturbations of each other; the coarser the
+	// quantization, the staler the results can be. Without Quantize, the
+	// target itself is the key. Either way, the key must be comparable.
+	Quantize func(target interface{}) interface{}
+
+	tree     *VPTree
+	capacity int
+
+	mu      sync.Mutex
+	entries map[cacheKey]*list.Element
+	lru     *list.List
+	hits    int
+	misses  int
+}
+
+type cacheKey struct {
+	target           interface{}
+	k                int
+	maxDistance      float64
+	maxNodes         int
+	maxSubtrees      int
+	subtreeDepth     int
+	expandDuplicates bool
+}
+
+type cacheEntry struct {
+	key       cacheKey
+	results   []interface{}
+	distances []float64
+}
+
+// NewCachingVPTree wraps tree in a cache that holds the results of up to
+// capacity searches, evicting the least recently used ones first.
+func NewCachingVPTree(tree *VPTree, capacity int) *CachingVPTree {
+	return &CachingVPTree{
+		tree:     tree,
+		capacity: capacity,
+		entries:  make(map[cacheKey]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Search is like VPTree.SearchWithParameters, but returns cached results if
+// the same search has been done before. Callbacks in p are only invoked when
+// the tree is actually searched.
+//
+// Functions in p that change the results, i.e. Exclude, PayloadResolver,
+// IDLookup, Less and QuantizeTarget, can not be compared, so searches that
+// set any of them bypass the cache: they always search the tree, and are not
+// counted as hits or misses.
+func (c *CachingVPTree) Search(target interface{}, p SearchParameters) (results []interface{}, distances []float64) {
+	if !cacheable(p) {
+		return c.tree.SearchWithParameters(target, p)
+	}
+
+	key := c.key(target, p)
+	if results, distances, ok := c.lookup(key); ok {
+		return results, distances
+	}
+
+	results, distances = c.tree.SearchWithParameters(target, p)
+	c.store(key, results, distances)
+
+	return copyResults(results, distances)
+}
+
+// Prewarm searches for all targets that are not cached yet and caches the
+// results, e.g. for popular queries ahead of traffic, so that they do not
+// cause latency spikes later. It searches concurrently, using up to GOMAXPROCS
+// goroutines, and does not count towards the cache statistics. It does not
+// call the OnResult and OnPruneDecision callbacks of p, since it returns no
+// results. It does nothing if p bypasses the cache; see Search.
+func (c *CachingVPTree) Prewarm(targets []interface{}, p SearchParameters) {
+	if !cacheable(p) {
+		return
+	}
+	p.OnResult, p.OnPruneDecision = nil, nil
+
+	work := make(chan interface{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for target := range work {
+				key := c.key(target, p)
+				if c.cached(key) {
+					continue
+				}
+				results, distances := c.tree.SearchWithParameters(target, p)
+				c.store(key, results, distances)
+			}
+		}()
+	}
+
+	for _, target := range targets {
+		work <- target
+	}
+	close(work)
+	wg.Wait()
+}
+
+// CacheStats returns the number of cache hits and misses so far.
+func (c *CachingVPTree) CacheStats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.hits, c.misses
+}
+
+func (c *CachingVPTree) key(target interface{}, p SearchParameters) cacheKey {
+	key := cacheKey{target, p.K, p.MaxDistance, p.MaxNodes, p.MaxSubtrees, p.SubtreeDepth, p.ExpandDuplicates}
+	if c.Quantize != nil {
+		key.target = c.Quantize(key.target)
+	}
+	return key
+}
+
+// cacheable reports whether the results of searches with p can be cached,
+// which is the case unless p has functions that change the results.
+func cacheable(p SearchParameters) bool {
+	return p.Exclude == nil && p.PayloadResolver == nil && p.IDLookup == nil && p.Less == nil && p.QuantizeTarget == nil
+}
+
+// cached reports whether there is an entry for key, without counting it as a
+// hit or miss.
+func (c *CachingVPTree) cached(key cacheKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.entries[key]
+	return ok
+}
+
+func (c *CachingVPTree) lookup(key cacheKey) ([]interface{}, []float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return nil, nil, false
+	}
+
+	c.hits++
+	c.lru.MoveToFront(e)
+	entry := e.Value.(*cacheEntry)
+	results, distances := copyResults(entry.results, entry.distances)
+
+	return results, distances, true
+}
+
+func (c *CachingVPTree) store(key cacheKey, results []interface{}, distances []float64) {
+	if c.capacity < 1 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key, results, distances})
+
+	for c.lru.Len() > c.capacity {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// copyResults copies results and distances, so that callers can not modify
+// the cached slices.
+func copyResults(results []interface{}, distances []float64) ([]interface{}, []float64) {
+	if results == nil {
+		return nil, nil
+	}
+
+	return append([]interface{}(nil), results...), append([]float64(nil), distances...)
+}
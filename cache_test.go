@@ -0,0 +1,203 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func newRandomCoordinateTree(n int) *VPTree {
+	vpitems := make([]interface{}, n)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	return New(CoordinateMetric, vpitems)
+}
+
+// This test makes sure repeated searches are answered from the cache
+func TestCachingVPTree(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	c := NewCachingVPTree(vp, 2)
+
+	q1 := Coordinate{0.1, 0.2}
+	q2 := Coordinate{0.3, 0.4}
+	q3 := Coordinate{0.5, 0.6}
+	p := SearchParameters{K: 5}
+
+	expected, expectedDists := vp.SearchWithParameters(q1, p)
+	results, distances := c.Search(q1, p)
+	compareResults(t, results, distances, expected, expectedDists)
+
+	results, distances = c.Search(q1, p)
+	compareResults(t, results, distances, expected, expectedDists)
+
+	if hits, misses := c.CacheStats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %v and %v", hits, misses)
+	}
+
+	// A different K must not be answered from the cache
+	c.Search(q1, SearchParameters{K: 6})
+	if hits, misses := c.CacheStats(); hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %v and %v", hits, misses)
+	}
+
+	// Filling the cache evicts the least recently used entry
+	c.Search(q2, p)
+	c.Search(q3, p)
+	c.Search(q1, p)
+	if hits, misses := c.CacheStats(); hits != 1 || misses != 5 {
+		t.Errorf("Expected 1 hit and 5 misses, got %v and %v", hits, misses)
+	}
+}
+
+// This test makes sure targets that quantize to the same key share a cache
+// entry
+func TestCachingVPTreeQuantize(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	c := NewCachingVPTree(vp, 10)
+	c.Quantize = func(target interface{}) interface{} {
+		q := target.(Coordinate)
+		return Coordinate{math.Round(q.X * 10), math.Round(q.Y * 10)}
+	}
+
+	p := SearchParameters{K: 5}
+	expected, expectedDists := c.Search(Coordinate{0.501, 0.499}, p)
+	results, distances := c.Search(Coordinate{0.499, 0.502}, p)
+
+	if hits, misses := c.CacheStats(); hits != 1 || misses != 1 {
+		t.Errorf("Expected 1 hit and 1 miss, got %v and %v", hits, misses)
+	}
+	compareResults(t, results, distances, expected, expectedDists)
+
+	c.Search(Coordinate{0.9, 0.9}, p)
+	if hits, misses := c.CacheStats(); hits != 1 || misses != 2 {
+		t.Errorf("Expected 1 hit and 2 misses, got %v and %v", hits, misses)
+	}
+}
+
+func compareResults(t *testing.T, results []interface{}, distances []float64, expected []interface{}, expectedDists []float64) {
+	t.Helper()
+
+	if len(results) != len(expected) || len(distances) != len(expectedDists) {
+		t.Fatalf("Expected %v results, got %v", len(expected), len(results))
+	}
+	for i := range results {
+		if results[i] != expected[i] || distances[i] != expectedDists[i] {
+			t.Errorf("Expected result %v to be (%v, %v), got (%v, %v)", i, expected[i], expectedDists[i], results[i], distances[i])
+		}
+	}
+}
+
+// This test makes sure prewarmed targets are answered from the cache without
+// searching the tree
+func TestCachingVPTreePrewarm(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	c := NewCachingVPTree(vp, 100)
+
+	var targets []interface{}
+	for i := 0; i < 50; i++ {
+		targets = append(targets, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	// The callbacks are not safe for concurrent use, so Prewarm must not
+	// call them
+	searched := 0
+	p := SearchParameters{
+		K: 5,
+		OnResult: func(target, result interface{}, dist float64, rank int) {
+			searched++
+		},
+		OnPruneDecision: func(nodeItem interface{}, dist, tau, threshold float64, visitedLeft, visitedRight bool) {
+			searched++
+		},
+	}
+	c.Prewarm(targets, p)
+
+	if hits, misses := c.CacheStats(); hits != 0 || misses != 0 {
+		t.Errorf("Expected Prewarm not to count hits or misses, got %v and %v", hits, misses)
+	}
+	if searched != 0 {
+		t.Errorf("Expected Prewarm not to call the callbacks, got %v calls", searched)
+	}
+
+	for _, q := range targets {
+		expected, expectedDists := vp.Search(q, 5)
+		results, distances := c.Search(q, p)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+
+	if searched != 0 {
+		t.Errorf("Expected no searches of the tree, got %v results from it", searched)
+	}
+	if hits, misses := c.CacheStats(); hits != len(targets) || misses != 0 {
+		t.Errorf("Expected %v hits and no misses, got %v and %v", len(targets), hits, misses)
+	}
+}
+
+// This test makes sure near-identical targets that quantize to the same
+// representative get identical results, also through a cache
+func TestQuantizeTarget(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	snap := func(target interface{}) interface{} {
+		c := target.(Coordinate)
+		return Coordinate{math.Round(c.X*100) / 100, math.Round(c.Y*100) / 100}
+	}
+	p := SearchParameters{K: 10, QuantizeTarget: snap}
+
+	q1 := Coordinate{0.421, 0.698}
+	q2 := Coordinate{0.4205, 0.7018}
+	results1, distances1 := vp.SearchWithParameters(q1, p)
+	results2, distances2 := vp.SearchWithParameters(q2, p)
+	compareResults(t, results2, distances2, results1, distances1)
+
+	expected, expectedDists := vp.Search(Coordinate{0.42, 0.70}, 10)
+	compareResults(t, results1, distances1, expected, expectedDists)
+
+	// The cache can not compare QuantizeTarget functions, so it is bypassed
+	c := NewCachingVPTree(vp, 10)
+	c.Search(q1, p)
+	results3, distances3 := c.Search(q2, p)
+	compareResults(t, results3, distances3, results1, distances1)
+	if hits, misses := c.CacheStats(); hits != 0 || misses != 0 {
+		t.Errorf("expected the cache to be bypassed, got %v hits and %v misses", hits, misses)
+	}
+}
+
+// This test makes sure searches with different parameters do not share cache
+// entries, and searches with functions that change the results bypass the
+// cache
+func TestCachingVPTreeParameters(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	c := NewCachingVPTree(vp, 10)
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	plain, _ := c.Search(q, SearchParameters{K: 5})
+
+	excluded := plain[0]
+	p := SearchParameters{
+		K: 5,
+		Exclude: func(item interface{}) bool {
+			return item == excluded
+		},
+	}
+	expected, expectedDists := vp.SearchWithParameters(q, p)
+	results, distances := c.Search(q, p)
+	compareResults(t, results, distances, expected, expectedDists)
+	for _, item := range results {
+		if item == excluded {
+			t.Errorf("expected %v to be excluded, got %v", excluded, results)
+		}
+	}
+
+	if hits, misses := c.CacheStats(); hits != 0 || misses != 1 {
+		t.Errorf("expected the search with Exclude to bypass the cache, got %v hits and %v misses", hits, misses)
+	}
+
+	c.Search(q, SearchParameters{K: 5, MaxNodes: 3})
+	c.Search(q, SearchParameters{K: 5, MaxSubtrees: 1})
+	c.Search(q, SearchParameters{K: 5, MaxSubtrees: 1, SubtreeDepth: 2})
+	c.Search(q, SearchParameters{K: 5, ExpandDuplicates: true})
+	if hits, misses := c.CacheStats(); hits != 0 || misses != 5 {
+		t.Errorf("expected different parameters to miss the cache, got %v hits and %v misses", hits, misses)
+	}
+}
@@ -0,0 +1,72 @@
+package vptree
+
+import "math/rand"
+
+// A ChurnStat describes the VP-tree after a removal; see ChurnProfile.
+type ChurnStat struct {
+	// Removed reports whether the item was found and removed.
+	Removed bool
+
+	// Size is the number of items left in the tree.
+	Size int
+
+	// MaxDepth is the number of nodes on the longest path from the root to
+	// a leaf.
+	MaxDepth int
+
+	// AvgDepth is the average number of nodes on the path from the root to
+	// an item. Searches visit at least that many nodes, so it tracks their
+	// cost as the tree changes.
+	AvgDepth float64
+}
+
+// ChurnProfile simulates removing the items of removeSeq from the VP-tree in
+// order and returns the shape of the tree after each removal. Items are
+// removed if equal reports them as equal, or, if equal is nil, if they are at
+// distance 0. The VP-tree itself is not modified.
+//
+// Removals rebuild the subtrees below the removed items, which keeps those
+// balanced but may unbalance their ancestors. The profile shows how quickly
+// this degrades the tree, which helps to decide how often a tree under churn
+// should be rebuilt.
+func (vp *VPTree) ChurnProfile(removeSeq []interface{}, equal func(a, b interface{}) bool) []ChurnStat {
+	// The copy gets its own random source and no arena, so that rebuilding
+	// its subtrees neither advances vp's source nor uses vp's nodes
+	t := *vp
+	t.root = vp.root.clone()
+	src := *vp.src
+	t.src = &src
+	t.rnd = rand.New(t.src)
+	t.arena = nil
+
+	profile := make([]ChurnStat, len(removeSeq))
+	for i, item := range removeSeq {
+		removed := t.removeEqual(item, equal)
+		stats := t.Stats()
+		profile[i] = ChurnStat{
+			Removed:  removed,
+			Size:     stats.Size,
+			MaxDepth: stats.MaxDepth,
+			AvgDepth: stats.AvgDepth,
+		}
+	}
+
+	return profile
+}
+
+// clone returns a deep copy of the subtree rooted at n.
+func (n *node) clone() *node {
+	if n == nil {
+		return nil
+	}
+
+	c := *n
+	c.Left, c.Right = n.Left.clone(), n.Right.clone()
+	if n.Bucket != nil {
+		c.Bucket = make([]*node, len(n.Bucket))
+		for i, b := range n.Bucket {
+			c.Bucket[i] = b.clone()
+		}
+	}
+	return &c
+}
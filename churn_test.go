@@ -0,0 +1,61 @@
+package vptree
+
+import (
+	"bytes"
+	"testing"
+)
+
+// This test removes the items of a chain of inserts from the bottom up, so
+// every removal shortens the chain by one
+func TestChurnProfile(t *testing.T) {
+	const n = 50
+
+	chain := New(absMetric, nil)
+	var seq []interface{}
+	for i := 0; i < n; i++ {
+		chain.Insert(float64(i))
+		seq = append([]interface{}{float64(i)}, seq...)
+	}
+	seq = append(seq, float64(-1))
+
+	profile := chain.ChurnProfile(seq, func(a, b interface{}) bool {
+		return a.(float64) == b.(float64)
+	})
+
+	if len(profile) != len(seq) {
+		t.Fatalf("Expected %v stats, got %v", len(seq), len(profile))
+	}
+	for i, stat := range profile[:n] {
+		if !stat.Removed || stat.Size != n-1-i || stat.MaxDepth != n-1-i {
+			t.Errorf("Expected %v items in a chain after %v removals, got %+v", n-1-i, i+1, stat)
+		}
+	}
+	if profile[n].Removed {
+		t.Error("Expected a missing item not to be removed")
+	}
+
+	if size := chain.Stats().Size; size != n {
+		t.Errorf("Expected the original tree to keep %v items, got %v", n, size)
+	}
+	if err := chain.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// This test makes sure ChurnProfile leaves the random source of the tree
+// alone, although the removals rebuild subtrees
+func TestChurnProfileRandState(t *testing.T) {
+	vp := newRandomCoordinateTree(500)
+	before := vp.RandState()
+
+	// Removing the top of the tree rebuilds large subtrees
+	seq := []interface{}{vp.root.Item, vp.root.Right.Item}
+	if vp.root.Left != nil {
+		seq = append(seq, vp.root.Left.Item)
+	}
+	vp.ChurnProfile(seq, nil)
+
+	if after := vp.RandState(); !bytes.Equal(before, after) {
+		t.Errorf("expected the random state %x to be unchanged, got %x", before, after)
+	}
+}
@@ -0,0 +1,145 @@
+package vptree
+
+import "math"
+
+// ClusterAssignments partitions the items of the VP-tree into hierarchical
+// clusters given by the tree structure: all items in the same subtree rooted
+// at the given depth (the root is at depth 0) get the same cluster id, so
+// there are at most 2^depth clusters. The vantage points above that depth are
+// assigned to the cluster of their left (inner) subtree, or of their right
+// subtree if they have no left one. Cluster ids are numbered from 0.
+//
+// The items are used as map keys, so they must be comparable; equal items are
+// reported with the cluster of the one visited last.
+func (vp *VPTree) ClusterAssignments(depth int) map[interface{}]int {
+	clusters := make(map[interface{}]int)
+	for n, id := range vp.clusterNodes(depth) {
+		clusters[n.Item] = id
+	}
+	return clusters
+}
+
+// clusterNodes implements ClusterAssignments, but labels nodes instead of
+// items.
+func (vp *VPTree) clusterNodes(depth int) map[*node]int {
+	clusters := make(map[*node]int)
+	next := 0
+
+	var label func(n *node, d int) int
+	label = func(n *node, d int) int {
+		if d >= depth {
+			id := next
+			next++
+			n.walk(func(c *node) {
+				clusters[c] = id
+			})
+			return id
+		}
+
+		id := -1
+		if n.Left != nil {
+			id = label(n.Left, d+1)
+		}
+		if n.Right != nil {
+			if rightID := label(n.Right, d+1); id < 0 {
+				id = rightID
+			}
+		}
+		if id < 0 {
+			id = next
+			next++
+		}
+
+		clusters[n] = id
+		for _, b := range n.Bucket {
+			b.walk(func(c *node) {
+				clusters[c] = id
+			})
+		}
+
+		return id
+	}
+
+	if vp.root != nil {
+		label(vp.root, 0)
+	}
+
+	return clusters
+}
+
+// NearestUnlike returns the nearest neighbour of target whose label differs
+// from that of target, and its distance, with labels given by labelOf. ok is
+// false if all items have the same label as target.
+func (vp *VPTree) NearestUnlike(target interface{}, labelOf func(item interface{}) int) (item interface{}, distance float64, ok bool) {
+	label := labelOf(target)
+	results, distances := vp.SearchWithParameters(target, SearchParameters{
+		K: 1,
+		Exclude: func(item interface{}) bool {
+			return labelOf(item) == label
+		},
+	})
+	if len(results) == 0 {
+		return nil, math.Inf(1), false
+	}
+
+	return results[0], distances[0], true
+}
+
+// SilhouetteScore evaluates the clustering of the items of the VP-tree given
+// by labelOf and returns its mean silhouette, between -1 and 1. The higher it
+// is, the closer items are to their own cluster compared to the neighbouring
+// one, so values near 1 indicate compact, well separated clusters, and
+// negative values indicate that many items would fit better elsewhere.
+//
+// The silhouette of an item is (b-a)/max(a, b), where a is its mean distance
+// to the other items of its cluster, and b is its mean distance to the items
+// of the neighbouring cluster. The neighbouring cluster is the one of the
+// nearest item with a different label, found with NearestUnlike, instead of
+// the one with the smallest mean distance, which would require measuring the
+// distance to every item. For well-separated clusters, both are the same.
+// Items that are alone in their cluster have a silhouette of 0, as do all
+// items if there is only one cluster.
+func (vp *VPTree) SilhouetteScore(labelOf func(item interface{}) int) float64 {
+	items := vp.Items()
+	if len(items) == 0 {
+		return 0
+	}
+
+	clusters := make(map[int][]interface{})
+	for _, item := range items {
+		label := labelOf(item)
+		clusters[label] = append(clusters[label], item)
+	}
+
+	// meanDistance returns the sum of the distances from item to the items
+	// of cluster, divided by n. For its own cluster, n does not count item,
+	// whose distance to itself is 0.
+	meanDistance := func(item interface{}, cluster []interface{}, n int) float64 {
+		sum := 0.0
+		for _, c := range cluster {
+			sum += vp.distanceMetric(item, c)
+		}
+		return sum / float64(n)
+	}
+
+	total := 0.0
+	for _, item := range items {
+		own := clusters[labelOf(item)]
+		if len(own) < 2 {
+			continue
+		}
+		neighbour, _, ok := vp.NearestUnlike(item, labelOf)
+		if !ok {
+			continue
+		}
+
+		a := meanDistance(item, own, len(own)-1)
+		other := clusters[labelOf(neighbour)]
+		b := meanDistance(item, other, len(other))
+		if m := math.Max(a, b); m > 0 {
+			total += (b - a) / m
+		}
+	}
+
+	return total / float64(len(items))
+}
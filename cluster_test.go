@@ -0,0 +1,126 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure the items of every subtree at the given depth share a
+// cluster id that no other subtree uses
+func TestClusterAssignments(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+
+	for _, depth := range []int{0, 1, 3, 5} {
+		clusters := vp.ClusterAssignments(depth)
+		if len(clusters) != 1000 {
+			t.Fatalf("Expected 1000 assigned items, got %v", len(clusters))
+		}
+
+		ids := make(map[int]bool)
+		for _, id := range clusters {
+			ids[id] = true
+		}
+		if len(ids) > 1<<uint(depth) {
+			t.Errorf("Expected at most %v clusters at depth %v, got %v", 1<<uint(depth), depth, len(ids))
+		}
+
+		seen := make(map[int]bool)
+		var check func(n *node, d int)
+		check = func(n *node, d int) {
+			if n == nil {
+				return
+			}
+			if d < depth {
+				check(n.Left, d+1)
+				check(n.Right, d+1)
+				return
+			}
+
+			id := clusters[n.Item]
+			if seen[id] {
+				t.Errorf("Expected cluster %v to belong to a single subtree", id)
+			}
+			seen[id] = true
+
+			n.walk(func(c *node) {
+				if clusters[c.Item] != id {
+					t.Errorf("Expected %v to be in cluster %v, got %v", c.Item, id, clusters[c.Item])
+				}
+			})
+		}
+		check(vp.root, 0)
+	}
+}
+
+// This test makes sure an empty tree has no clusters
+func TestClusterAssignmentsEmpty(t *testing.T) {
+	vp := New(CoordinateMetric, nil)
+	if clusters := vp.ClusterAssignments(rand.Intn(5)); len(clusters) != 0 {
+		t.Errorf("Expected no clusters, got %v", clusters)
+	}
+}
+
+// This test compares SilhouetteScore against a brute-force silhouette on well
+// separated clusters, and NearestUnlike against a brute-force search
+func TestSilhouetteScore(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	centers := []Coordinate{{0, 0}, {10, 0}, {-5, 40}}
+
+	var items []interface{}
+	label := make(map[interface{}]int)
+	for c, center := range centers {
+		for i := 0; i < 40; i++ {
+			item := Coordinate{X: center.X + r.Float64(), Y: center.Y + r.Float64()}
+			items = append(items, item)
+			label[item] = c
+		}
+	}
+	labelOf := func(item interface{}) int {
+		return label[item]
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	expected := 0.0
+	for _, a := range items {
+		sums := make([]float64, len(centers))
+		counts := make([]int, len(centers))
+		for _, b := range items {
+			if a != b {
+				sums[labelOf(b)] += CoordinateMetric(a, b)
+				counts[labelOf(b)]++
+			}
+		}
+		intra := sums[labelOf(a)] / float64(counts[labelOf(a)])
+		inter := math.Inf(1)
+		for c := range centers {
+			if c != labelOf(a) {
+				inter = math.Min(inter, sums[c]/float64(counts[c]))
+			}
+		}
+		expected += (inter - intra) / math.Max(intra, inter)
+	}
+	expected /= float64(len(items))
+
+	if score := vp.SilhouetteScore(labelOf); math.Abs(score-expected) > 1e-9 {
+		t.Errorf("expected a silhouette of %v, got %v", expected, score)
+	}
+	if score := vp.SilhouetteScore(func(interface{}) int { return 0 }); score != 0 {
+		t.Errorf("expected a silhouette of 0 for a single cluster, got %v", score)
+	}
+
+	for _, q := range items[:10] {
+		var nearest interface{}
+		best := math.Inf(1)
+		for _, item := range items {
+			if d := CoordinateMetric(q, item); labelOf(item) != labelOf(q) && d < best {
+				nearest, best = item, d
+			}
+		}
+
+		item, dist, ok := vp.NearestUnlike(q, labelOf)
+		if !ok || item != nearest || dist != best {
+			t.Errorf("expected %v at %v to be the nearest unlike item, got %v at %v", nearest, best, item, dist)
+		}
+	}
+}
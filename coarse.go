@@ -0,0 +1,110 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+)
+
+// A coarseIndex partitions a VP-tree into the vantage points of its top
+// levels, called leaders, and the subtrees below them, called regions. For
+// every region and leader it stores the range of distances from the leader
+// to the items in the region, so that a search can bound the distance from
+// its target to each region using only its distances to the leaders.
+type coarseIndex struct {
+	leaders []*node
+	regions []*node
+
+	// rings[r*len(leaders)+l] holds the smallest and largest distance from
+	// leaders[l] to any item in regions[r].
+	rings [][2]float64
+}
+
+// BuildCoarseIndex precomputes a coarse index over the top levels of the
+// VP-tree, which Search consults to narrow a query down to its most promising
+// subtree before the fine-grained traversal. The index holds the distances
+// from the vantage points of the top levels, the leaders, to all items below
+// them, summarized per subtree. A search measures its target against all
+// 2^levels-1 leaders, bounds the distance to every subtree with the triangle
+// inequality, and searches the subtrees from the most to the least promising
+// one. The first subtree usually yields a tight tau, so most of the others are
+// pruned without visiting a single node, whereas a plain search has to start
+// with an unbounded tau and can only narrow it down on its way.
+//
+// This trades memory and build time for faster cold queries: building costs
+// 2^levels-1 metric calls per item, and every search costs that many metric
+// calls plus about 4^levels comparisons before it visits any other node.
+// Levels between 3 and 6 are usually a good trade. Results are exactly the
+// same as without the index. Searches with MaxNodes, MaxSubtrees or
+// OnPruneDecision do not use it. A levels of less than 1 removes the index.
+// Modifying the VP-tree discards the index, so it has to be built again
+// afterwards.
+func (vp *VPTree) BuildCoarseIndex(levels int) {
+	vp.coarse = nil
+	if levels < 1 || vp.root == nil {
+		return
+	}
+
+	ci := &coarseIndex{}
+
+	var split func(n *node, depth int)
+	split = func(n *node, depth int) {
+		if n == nil {
+			return
+		}
+		if depth == levels {
+			ci.regions = append(ci.regions, n)
+			return
+		}
+		ci.leaders = append(ci.leaders, n)
+		ci.regions = append(ci.regions, n.Bucket...)
+		split(n.Left, depth+1)
+		split(n.Right, depth+1)
+	}
+	split(vp.root, 0)
+
+	ci.rings = make([][2]float64, len(ci.regions)*len(ci.leaders))
+	for r, region := range ci.regions {
+		rings := ci.rings[r*len(ci.leaders) : (r+1)*len(ci.leaders)]
+		for l := range rings {
+			rings[l] = [2]float64{math.Inf(1), 0}
+		}
+		region.walk(func(n *node) {
+			for l, leader := range ci.leaders {
+				dist := vp.distanceMetric(leader.Item, n.Item)
+				rings[l][0] = math.Min(rings[l][0], dist)
+				rings[l][1] = math.Max(rings[l][1], dist)
+			}
+		})
+	}
+
+	vp.coarse = ci
+}
+
+// searchCoarse searches the whole VP-tree using the coarse index ci: it adds
+// the leaders to the results and then searches the regions in order of their
+// lower bound, pruning all those that lie beyond tau.
+func (s *searcher) searchCoarse(ci *coarseIndex) {
+	dists := make([]float64, len(ci.leaders))
+	for l, leader := range ci.leaders {
+		s.stats.NodesVisited++
+		s.stats.MetricCalls++
+		dists[l] = s.vp.distanceMetric(leader.Item, s.target)
+		s.add(leader, dists[l])
+	}
+
+	order := make([]int, len(ci.regions))
+	lower := make([]float64, len(ci.regions))
+	for r := range ci.regions {
+		order[r] = r
+		for l, ring := range ci.rings[r*len(ci.leaders) : (r+1)*len(ci.leaders)] {
+			lower[r] = math.Max(lower[r], math.Max(ring[0]-dists[l], dists[l]-ring[1]))
+		}
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return lower[order[i]] < lower[order[j]]
+	})
+
+	for _, r := range order {
+		s.visit(ci.regions[r], lower[r])
+	}
+}
@@ -0,0 +1,112 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure that searches seeded from the coarse index return
+// exactly the same results as unseeded ones
+func TestCoarseIndex(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	items := uniformVectors(r, 2000, 6)
+	plain := New(VectorMetric, append([]interface{}(nil), items...))
+	seeded := New(VectorMetric, append([]interface{}(nil), items...))
+
+	for _, levels := range []int{1, 3, 5, 20} {
+		seeded.BuildCoarseIndex(levels)
+		if seeded.coarse == nil {
+			t.Fatalf("levels %v: expected a coarse index", levels)
+		}
+
+		for _, k := range []int{1, 5, 50} {
+			for i := 0; i < 20; i++ {
+				q := uniformVectors(r, 1, 6)[0]
+				p := SearchParameters{K: k}
+				if i%2 == 1 {
+					p.MaxDistance = 0.3
+				}
+
+				_, expected := plain.SearchWithParameters(q, p)
+				_, distances := seeded.SearchWithParameters(q, p)
+				if len(distances) != len(expected) {
+					t.Fatalf("levels %v, k %v: expected %v results, got %v", levels, k, len(expected), len(distances))
+				}
+				for j := range distances {
+					if distances[j] != expected[j] {
+						t.Errorf("levels %v, k %v: expected distances[%v] to be %v, got %v", levels, k, j, expected[j], distances[j])
+					}
+				}
+			}
+		}
+	}
+
+	// The item itself must still be skipped when it is a leader
+	expected := plain.KDistancePlot(3)
+	distances := seeded.KDistancePlot(3)
+	for i := range distances {
+		if distances[i] != expected[i] {
+			t.Fatalf("expected distances[%v] to be %v, got %v", i, expected[i], distances[i])
+		}
+	}
+
+	// Budgeted searches do not use the index, so they keep to their budget
+	q := uniformVectors(r, 1, 6)[0]
+	for _, maxNodes := range []int{1, 3, 40} {
+		p := SearchParameters{K: 5, MaxNodes: maxNodes}
+		seeded.BuildCoarseIndex(0)
+		_, expected, expectedStats := seeded.SearchWithStats(q, p)
+		seeded.BuildCoarseIndex(5)
+		_, distances, stats := seeded.SearchWithStats(q, p)
+		if stats.NodesVisited != expectedStats.NodesVisited || stats.NodesVisited > maxNodes {
+			t.Errorf("MaxNodes %v: expected %v nodes to be visited, got %v", maxNodes, expectedStats.NodesVisited, stats.NodesVisited)
+		}
+		for j := range distances {
+			if distances[j] != expected[j] {
+				t.Errorf("MaxNodes %v: expected distances[%v] to be %v, got %v", maxNodes, j, expected[j], distances[j])
+			}
+		}
+	}
+	if _, _, confidence := seeded.SearchWithConfidence(q, SearchParameters{K: 5, MaxNodes: 3}); confidence >= 1 {
+		t.Errorf("expected a truncated search to have a confidence below 1, got %v", confidence)
+	}
+
+	seeded.Insert(uniformVectors(r, 1, 6)[0])
+	if seeded.coarse != nil {
+		t.Error("expected Insert to discard the coarse index")
+	}
+
+	seeded.BuildCoarseIndex(0)
+	if seeded.coarse != nil {
+		t.Error("expected levels 0 to remove the coarse index")
+	}
+}
+
+func BenchmarkCoarseIndex(b *testing.B) {
+	r := rand.New(rand.NewSource(3))
+	items := clusteredVectors(r, 20000, 8, 16)
+	queries := clusteredVectors(r, 1000, 8, 16)
+
+	for _, bm := range []struct {
+		name   string
+		levels int
+	}{
+		{"Without", 0},
+		{"Levels3", 3},
+		{"Levels5", 5},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			vp := New(VectorMetric, append([]interface{}(nil), items...))
+			vp.BuildCoarseIndex(bm.levels)
+			p := SearchParameters{K: 1}
+
+			calls := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _, stats := vp.SearchWithStats(queries[i%len(queries)], p)
+				calls += stats.MetricCalls
+			}
+			b.ReportMetric(float64(calls)/float64(b.N), "calls/op")
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package vptree
+
+// Compact reduces the height of the VP-tree by collapsing chains of nodes
+// that all split on the same threshold into a single node with a bucket of
+// leaves. Such chains arise when many items are equidistant from each other,
+// e.g. with duplicates or discrete metrics: partitioning them by distance does
+// not separate anything, so the tree degenerates into a list that is just as
+// slow to search but much deeper. The items in a bucket are searched
+// linearly. Compact does not change search results.
+func (vp *VPTree) Compact() {
+	compact(vp.root)
+	vp.coarse = nil
+	vp.epoch++
+}
+
+func compact(n *node) {
+	if n == nil || (n.Left == nil && n.Right == nil) {
+		return
+	}
+
+	// Only chains of at least two nodes are collapsed: the leaves below a
+	// single node can be pruned, but the leaves of a bucket can not.
+	if !hasChildren(n.Left) && !hasChildren(n.Right) ||
+		!sameThreshold(n.Left, n.Threshold) || !sameThreshold(n.Right, n.Threshold) {
+		compact(n.Left)
+		compact(n.Right)
+		return
+	}
+
+	// Everything below n splits on the same threshold, so move all of it
+	// into n's bucket.
+	var leaves []*node
+	for _, child := range []*node{n.Left, n.Right} {
+		child.walk(func(c *node) {
+			leaves = append(leaves, c)
+		})
+	}
+	for _, leaf := range leaves {
+		leaf.Threshold, leaf.Left, leaf.Right, leaf.Bucket = 0, nil, nil, nil
+		leaf.LeftRadius, leaf.RightRadius, leaf.Size = 0, 0, 1
+	}
+
+	n.Threshold, n.Left, n.Right = 0, nil, nil
+	n.LeftRadius, n.RightRadius = 0, 0
+	n.Bucket = append(n.Bucket, leaves...)
+}
+
+// hasChildren reports whether n is a node with children.
+func hasChildren(n *node) bool {
+	return n != nil && (n.Left != nil || n.Right != nil)
+}
+
+// sameThreshold reports whether all nodes with children in the subtree rooted
+// at n split on threshold.
+func sameThreshold(n *node, threshold float64) bool {
+	if n == nil || (n.Left == nil && n.Right == nil) {
+		return true
+	}
+
+	return n.Threshold == threshold && sameThreshold(n.Left, threshold) && sameThreshold(n.Right, threshold)
+}
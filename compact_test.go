@@ -0,0 +1,77 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure Compact reduces the depth of a tree over many
+// duplicates without changing search results
+func TestCompact(t *testing.T) {
+	vpitems := make([]interface{}, 1000)
+	for i := range vpitems {
+		vpitems[i] = float64(rand.Intn(10))
+	}
+	vp := New(absMetric, vpitems)
+
+	queries := make([]float64, 20)
+	for i := range queries {
+		queries[i] = rand.Float64() * 10
+	}
+
+	type result struct {
+		items     []interface{}
+		distances []float64
+	}
+	var before []result
+	for _, q := range queries {
+		items, distances := vp.Search(q, 75)
+		before = append(before, result{items, distances})
+	}
+
+	depth := vp.Stats().MaxDepth
+	vp.Compact()
+
+	stats := vp.Stats()
+	if stats.MaxDepth >= depth {
+		t.Errorf("Expected Compact to reduce the depth of %v, got %v", depth, stats.MaxDepth)
+	}
+	if stats.Size != len(vpitems) {
+		t.Errorf("Expected %v items after Compact, got %v", len(vpitems), stats.Size)
+	}
+
+	for i, q := range queries {
+		items, distances := vp.Search(q, 75)
+		compareResults(t, items, distances, before[i].items, before[i].distances)
+	}
+
+	// Inserting into a compacted tree keeps working
+	vp.Insert(3.5)
+	if items, _ := vp.Search(3.5, 1); len(items) != 1 || items[0] != 3.5 {
+		t.Errorf("Expected to find the inserted item, got %v", items)
+	}
+}
+
+// This test makes sure Compact leaves trees without chains of equal
+// thresholds alone, so that searches do not get more expensive
+func TestCompactRandom(t *testing.T) {
+	vp := newRandomCoordinateTree(2000)
+
+	queries := make([]Coordinate, 50)
+	for i := range queries {
+		queries[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	metricCalls := func() (calls int) {
+		for _, q := range queries {
+			_, _, stats := vp.SearchWithStats(q, SearchParameters{K: 5})
+			calls += stats.MetricCalls
+		}
+		return
+	}
+
+	before := metricCalls()
+	vp.Compact()
+	if after := metricCalls(); after > before {
+		t.Errorf("Expected Compact not to increase the metric calls of %v, got %v", before, after)
+	}
+}
@@ -0,0 +1,19 @@
+package vptree
+
+// SearchWithComputedDistances is like SearchWithParameters, but also returns
+// every item the metric was evaluated on during the search, together with its
+// distance to the target, in the order of evaluation. This includes items
+// that did not make it into the results, so with an expensive metric the
+// computed distances can be used to fill an external cache, e.g. to answer
+// later queries with the same target without evaluating the metric again.
+func (vp *VPTree) SearchWithComputedDistances(target interface{}, p SearchParameters) (results []interface{}, distances []float64, computed []Neighbor) {
+	t := *vp
+	t.distanceMetric = func(a, b interface{}) float64 {
+		dist := vp.distanceMetric(a, b)
+		computed = append(computed, Neighbor{a, dist})
+		return dist
+	}
+
+	results, distances = t.SearchWithParameters(target, p)
+	return results, distances, computed
+}
@@ -0,0 +1,55 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure the computed distances are exactly the metric
+// evaluations that the search made
+func TestSearchWithComputedDistances(t *testing.T) {
+	var calls []Neighbor
+	metric := func(a, b interface{}) float64 {
+		dist := CoordinateMetric(a, b)
+		calls = append(calls, Neighbor{a, dist})
+		return dist
+	}
+
+	items := make([]interface{}, 1000)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := New(metric, items)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		p := SearchParameters{K: 5}
+
+		calls = nil
+		expected, expectedDists := vp.SearchWithParameters(q, p)
+		expectedCalls := calls
+
+		calls = nil
+		results, distances, computed := vp.SearchWithComputedDistances(q, p)
+
+		if len(computed) != len(calls) || len(computed) != len(expectedCalls) {
+			t.Fatalf("expected %v computed distances, got %v", len(calls), len(computed))
+		}
+		for j := range computed {
+			if computed[j] != calls[j] {
+				t.Errorf("expected computed[%v] to be %v, got %v", j, calls[j], computed[j])
+			}
+		}
+		compareResults(t, results, distances, expected, expectedDists)
+
+		for j, r := range results {
+			found := false
+			for _, c := range computed {
+				found = found || (c.Item == r && c.Distance == distances[j])
+			}
+			if !found {
+				t.Errorf("expected result %v to be among the computed distances", r)
+			}
+		}
+	}
+}
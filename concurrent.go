@@ -0,0 +1,205 @@
+package vptree
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+var errRebuildInProgress = errors.New("vptree: a rebuild is already in progress")
+
+// A ConcurrentVPTree is a VP-tree that can be searched and inserted into
+// concurrently. Searches run without holding a lock against an immutable
+// snapshot of the tree; inserts copy the nodes they modify and swap in a new
+// snapshot, so they never disturb searches that are in progress.
+type ConcurrentVPTree struct {
+	mu   sync.RWMutex
+	tree *VPTree
+
+	// While a background rebuild is running, replay collects the items
+	// inserted since it started; see RebuildBackground.
+	rebuilding bool
+	replay     []interface{}
+
+	pendingMu sync.Mutex
+	pending   []interface{}
+
+	wake      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConcurrent wraps tree for concurrent use. The ConcurrentVPTree takes
+// ownership of tree, which must not be used directly afterwards. Close must
+// be called to stop the background goroutine that merges asynchronous inserts.
+func NewConcurrent(tree *VPTree) *ConcurrentVPTree {
+	c := &ConcurrentVPTree{
+		tree:    tree,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	go c.merge()
+
+	return c
+}
+
+// Search searches the tree for the k nearest neighbours of target, like
+// VPTree.Search.
+func (c *ConcurrentVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	return c.snapshot().Search(target, k)
+}
+
+// SearchWithParameters searches the tree like VPTree.SearchWithParameters.
+func (c *ConcurrentVPTree) SearchWithParameters(target interface{}, p SearchParameters) (results []interface{}, distances []float64) {
+	return c.snapshot().SearchWithParameters(target, p)
+}
+
+// Insert adds item to the tree. Searches that start after Insert returns will
+// see the item.
+func (c *ConcurrentVPTree) Insert(item interface{}) {
+	c.insertBatch([]interface{}{item})
+}
+
+// InsertAsync buffers item for insertion and returns immediately. Buffered
+// items are merged into the tree in batches by a background goroutine, so
+// searches may not find an item until some time after InsertAsync returns.
+// Flush merges all buffered items right away.
+func (c *ConcurrentVPTree) InsertAsync(item interface{}) {
+	c.pendingMu.Lock()
+	c.pending = append(c.pending, item)
+	c.pendingMu.Unlock()
+
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush merges all items buffered by InsertAsync into the tree.
+func (c *ConcurrentVPTree) Flush() {
+	c.pendingMu.Lock()
+	batch := c.pending
+	c.pending = nil
+	c.pendingMu.Unlock()
+
+	if len(batch) > 0 {
+		c.insertBatch(batch)
+	}
+}
+
+// Close flushes the buffered items and stops the background goroutine.
+// InsertAsync must not be called after Close.
+func (c *ConcurrentVPTree) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		<-c.stopped
+		c.Flush()
+	})
+}
+
+// Len returns the number of items in the tree, not counting buffered items.
+func (c *ConcurrentVPTree) Len() int {
+	return c.snapshot().size
+}
+
+// Epoch returns the epoch of the current tree; see VPTree.Epoch. Every insert
+// and every completed rebuild increments it.
+func (c *ConcurrentVPTree) Epoch() uint64 {
+	return c.snapshot().Epoch()
+}
+
+func (c *ConcurrentVPTree) snapshot() *VPTree {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.tree
+}
+
+func (c *ConcurrentVPTree) insertBatch(items []interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tree = c.tree.withInserted(items)
+	if c.rebuilding {
+		c.replay = append(c.replay, items...)
+	}
+}
+
+// RebuildBackground rebuilds the tree from its current items in a new
+// goroutine, using selector to choose the vantage points, or the tree's
+// current selector if selector is nil. Searches and inserts continue against
+// the old tree in the meantime. When the new tree is built, the items inserted
+// since the rebuild started are inserted into it as well, and it replaces the
+// old tree atomically. The returned channel receives nil once the new tree
+// is in place, or an error if the rebuild failed or another rebuild is already
+// in progress, in which case the old tree stays in place.
+func (c *ConcurrentVPTree) RebuildBackground(selector VantageSelector) <-chan error {
+	errc := make(chan error, 1)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.rebuilding {
+		errc <- errRebuildInProgress
+		return errc
+	}
+	c.rebuilding = true
+
+	go func(old *VPTree) {
+		errc <- c.rebuild(old, selector)
+	}(c.tree)
+
+	return errc
+}
+
+// rebuild builds a new tree from the items of old, which is a snapshot of the
+// tree, and swaps it in.
+func (c *ConcurrentVPTree) rebuild(old *VPTree, selector VantageSelector) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("vptree: rebuild failed: %v", r)
+		}
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.rebuilding, c.replay = false, nil
+	}()
+
+	t := *old
+	t.src = newRandSource()
+	t.rnd = rand.New(t.src)
+	t.auto = &autoModel{}
+	t.coarse = nil
+	if selector != nil {
+		t.selector = selector
+	}
+	t.root = t.buildFromPoints(old.Items(), 0)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, item := range c.replay {
+		t.Insert(item)
+	}
+	t.epoch = c.tree.epoch + 1
+	c.tree = &t
+
+	return nil
+}
+
+func (c *ConcurrentVPTree) merge() {
+	defer close(c.stopped)
+
+	for {
+		select {
+		case <-c.wake:
+			c.Flush()
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package vptree
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// This test inserts and searches concurrently and makes sure no item is lost
+func TestConcurrentInsertAsync(t *testing.T) {
+	var items []Coordinate
+	vpitems := make([]interface{}, 500)
+	for i := range vpitems {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		vpitems[i] = c
+	}
+	c := NewConcurrent(New(CoordinateMetric, vpitems))
+	defer c.Close()
+
+	inserted := make([][]Coordinate, 4)
+	var wg sync.WaitGroup
+
+	for i := range inserted {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for j := 0; j < 250; j++ {
+				item := Coordinate{X: r.Float64(), Y: r.Float64()}
+				inserted[i] = append(inserted[i], item)
+				if j%2 == 0 {
+					c.InsertAsync(item)
+				} else {
+					c.Insert(item)
+				}
+			}
+		}(i)
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+				results, distances := c.Search(q, 5)
+				if len(results) != 5 {
+					t.Errorf("Expected 5 results, got %v", len(results))
+					return
+				}
+				for k := range results {
+					if CoordinateMetric(q, results[k]) != distances[k] {
+						t.Errorf("Result %v does not match its distance %v", results[k], distances[k])
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	c.Flush()
+
+	for _, batch := range inserted {
+		items = append(items, batch...)
+	}
+	if c.Len() != len(items) {
+		t.Fatalf("Expected %v items, got %v", len(items), c.Len())
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		coords1, distances1 := c.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+}
+
+// This test inserts and searches concurrently while the tree is rebuilt in
+// the background and makes sure no insert is lost
+func TestConcurrentRebuildBackground(t *testing.T) {
+	var items []Coordinate
+	vpitems := make([]interface{}, 5000)
+	for i := range vpitems {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		vpitems[i] = c
+	}
+	c := NewConcurrent(New(CoordinateMetric, vpitems))
+	defer c.Close()
+
+	errc := c.RebuildBackground(SelectorMinSearchCost)
+	if err := <-c.RebuildBackground(nil); err == nil {
+		t.Error("Expected a second rebuild to fail while the first is in progress")
+	}
+
+	inserted := make([][]Coordinate, 4)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for i := range inserted {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for j := 0; ; j++ {
+				item := Coordinate{X: r.Float64(), Y: r.Float64()}
+				inserted[i] = append(inserted[i], item)
+				c.Insert(item)
+
+				results, _ := c.Search(item, 1)
+				if len(results) != 1 || results[0] != item {
+					t.Errorf("Expected to find %v right after inserting it, got %v", item, results)
+					return
+				}
+
+				select {
+				case <-stop:
+					if j >= 100 {
+						return
+					}
+				default:
+				}
+			}
+		}(i)
+	}
+
+	if err := <-errc; err != nil {
+		t.Fatalf("Expected the rebuild to succeed, got %v", err)
+	}
+	close(stop)
+	wg.Wait()
+
+	for _, batch := range inserted {
+		items = append(items, batch...)
+	}
+	if c.Len() != len(items) {
+		t.Fatalf("Expected %v items, got %v", len(items), c.Len())
+	}
+	if err := c.snapshot().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		coords1, distances1 := c.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+
+	if err := <-c.RebuildBackground(nil); err != nil {
+		t.Errorf("Expected another rebuild to succeed, got %v", err)
+	}
+	if c.Len() != len(items) {
+		t.Errorf("Expected %v items after another rebuild, got %v", len(items), c.Len())
+	}
+}
@@ -0,0 +1,33 @@
+package vptree
+
+// SearchWithConfidence is like SearchWithParameters, but also estimates how
+// likely it is that the true nearest neighbour is among the results. This is
+// useful for approximate searches limited by p.MaxNodes, to decide whether to
+// escalate to an exact search.
+//
+// If the search did not have to skip any node because of MaxNodes, the results
+// are exact and the confidence is 1. Otherwise, the estimate is a heuristic:
+// the fraction of the visited nodes that were visited after the nearest
+// result was found. A nearest result that was found early and survived a long
+// search without being improved upon is likely the true nearest neighbour,
+// whereas one that was only found just before the search was cut off likely
+// has closer neighbours in the skipped part of the tree.
+func (vp *VPTree) SearchWithConfidence(target interface{}, p SearchParameters) (results []interface{}, distances []float64, confidence float64) {
+	if p.K < 1 {
+		return nil, nil, 1
+	}
+
+	s := vp.newSearcher(target, p)
+	s.run()
+	results, distances = s.results()
+
+	if !s.truncated {
+		return results, distances, 1
+	}
+	if len(results) == 0 {
+		return results, distances, 0
+	}
+
+	visited := s.stats.NodesVisited
+	return results, distances, float64(visited-s.nearestAt) / float64(visited)
+}
@@ -0,0 +1,35 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure exact searches report full confidence and truncated
+// searches report less
+func TestSearchWithConfidence(t *testing.T) {
+	vp := newRandomCoordinateTree(5000)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		expected, expectedDists := vp.Search(q, 5)
+		results, distances, confidence := vp.SearchWithConfidence(q, SearchParameters{K: 5})
+		if confidence != 1 {
+			t.Errorf("Expected an exact search to have confidence 1, got %v", confidence)
+		}
+		compareResults(t, results, distances, expected, expectedDists)
+
+		// 10 nodes of 5000 always truncate the search
+		_, _, confidence = vp.SearchWithConfidence(q, SearchParameters{K: 5, MaxNodes: 10})
+		if confidence < 0 || confidence >= 1 {
+			t.Errorf("Expected a truncated search to have a confidence in [0, 1), got %v", confidence)
+		}
+	}
+
+	// A budget larger than the tree does not truncate the search
+	_, _, confidence := vp.SearchWithConfidence(Coordinate{}, SearchParameters{K: 5, MaxNodes: 5000})
+	if confidence != 1 {
+		t.Errorf("Expected an untruncated search to have confidence 1, got %v", confidence)
+	}
+}
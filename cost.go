@@ -0,0 +1,82 @@
+package vptree
+
+import "math"
+
+// costRebuildRatio is the ratio of actual to ideal search cost above which
+// SearchCostAnalysis recommends a rebuild.
+const costRebuildRatio = 2
+
+// A CostAnalysis compares how many nodes searches visit in a VP-tree with how
+// many they would visit in a perfectly balanced tree that prunes equally well.
+type CostAnalysis struct {
+	// Tree describes the shape of the analyzed tree.
+	Tree TreeStats
+
+	// Profile summarizes the work done for the sample queries.
+	Profile QueryProfile
+
+	// IdealNodesVisited is the average number of nodes the sample queries
+	// would visit in a perfectly balanced tree of the same size.
+	IdealNodesVisited float64
+
+	// Ratio is Profile.AvgNodesVisited divided by IdealNodesVisited. It is
+	// close to 1 for a well-balanced tree and grows as the tree degenerates.
+	Ratio float64
+
+	// RebuildRecommended is set if Ratio is above 2, in which case
+	// rebuilding the tree, e.g. with Rebuild or with NewWithSelector and
+	// SelectorSpread, should speed up searches considerably.
+	RebuildRecommended bool
+}
+
+// SearchCostAnalysis searches for the k nearest neighbours of every one of
+// sampleQueries and compares the average number of nodes visited with a
+// theoretical ideal, to quantify how much a rebuild would help.
+//
+// The ideal is derived from the observed pruning: every search visits on
+// average b children of each node that it expands, between 1 (perfect
+// pruning) and 2 (no pruning at all). A search that expands nodes at the same
+// rate in a perfectly balanced tree of n items, which is log2(n+1) levels
+// deep, visits 1 + b + b^2 + ... nodes, one term per level. In an unbalanced
+// tree, searches have to go deeper to reach the same items, so they visit
+// more nodes than that. The coarse index, if any, is not used.
+func (vp *VPTree) SearchCostAnalysis(sampleQueries []interface{}, k int) (analysis CostAnalysis) {
+	analysis.Tree = vp.Stats()
+	if len(sampleQueries) == 0 || k < 1 || vp.size == 0 {
+		return
+	}
+
+	levels := math.Log2(float64(vp.size + 1))
+
+	var nodes, calls int
+	var ideal float64
+	for _, target := range sampleQueries {
+		s := vp.newSearcher(target, SearchParameters{K: k})
+		s.search(vp.root, 0)
+		nodes += s.stats.NodesVisited
+		calls += s.stats.MetricCalls
+
+		b := 1.0
+		if s.expanded > 0 {
+			b = math.Min(float64(s.stats.NodesVisited-1)/float64(s.expanded), 2)
+		}
+		if b > 1 {
+			ideal += (math.Pow(b, levels) - 1) / (b - 1)
+		} else {
+			ideal += levels
+		}
+	}
+
+	queries := float64(len(sampleQueries))
+	analysis.Profile = QueryProfile{
+		Queries:         len(sampleQueries),
+		AvgNodesVisited: float64(nodes) / queries,
+		AvgMetricCalls:  float64(calls) / queries,
+		PrunedFraction:  1 - float64(nodes)/queries/float64(vp.size),
+	}
+	analysis.IdealNodesVisited = math.Min(ideal/queries, float64(vp.size))
+	analysis.Ratio = analysis.Profile.AvgNodesVisited / analysis.IdealNodesVisited
+	analysis.RebuildRecommended = analysis.Ratio > costRebuildRatio
+
+	return
+}
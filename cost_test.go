@@ -0,0 +1,44 @@
+package vptree
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test compares the analysis of a balanced tree with that of a
+// degenerate one built by inserting sorted items
+func TestSearchCostAnalysis(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	items := make([]interface{}, 2000)
+	for i := range items {
+		items[i] = r.Float64()
+	}
+	queries := make([]interface{}, 100)
+	for i := range queries {
+		queries[i] = r.Float64()
+	}
+
+	balanced := New(absMetric, append([]interface{}(nil), items...))
+	sort.Slice(items, func(i, j int) bool { return items[i].(float64) < items[j].(float64) })
+	degenerate := New(absMetric, nil)
+	for _, item := range items {
+		degenerate.Insert(item)
+	}
+
+	for _, k := range []int{1, 10} {
+		good := balanced.SearchCostAnalysis(queries, k)
+		bad := degenerate.SearchCostAnalysis(queries, k)
+
+		if good.Profile.Queries != len(queries) || good.Tree.Size != len(items) {
+			t.Errorf("k=%v: expected %v queries on %v items, got %+v", k, len(queries), len(items), good)
+		}
+		if good.RebuildRecommended || good.Ratio > costRebuildRatio {
+			t.Errorf("k=%v: expected no rebuild for a balanced tree, got ratio %v", k, good.Ratio)
+		}
+		if !bad.RebuildRecommended || bad.Ratio < 10*good.Ratio {
+			t.Errorf("k=%v: expected a rebuild for a degenerate tree, got ratio %v", k, bad.Ratio)
+		}
+	}
+}
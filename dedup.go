@@ -0,0 +1,76 @@
+package vptree
+
+// NewDeduplicated is like New, but keeps only the first of every group of
+// duplicate items. Items are grouped by hash first, so that finding the
+// duplicates takes linear time instead of a metric evaluation per pair of
+// items; items with the same hash are duplicates only if equal reports them
+// as equal. If equal is nil, items with the same hash are duplicates if the
+// metric measures a distance of 0 between them.
+//
+// The tree remembers all duplicates of every item it keeps, e.g. all
+// documents with the same content hash, so that Duplicates and searches with
+// SearchParameters.ExpandDuplicates can return every one of them. It forgets
+// them as soon as items are inserted or removed, since those may not be
+// grouped consistently with the duplicates any more, and Save does not save
+// them.
+func NewDeduplicated(metric Metric, items []interface{}, hash func(item interface{}) uint64, equal func(a, b interface{}) bool) *VPTree {
+	if equal == nil {
+		equal = func(a, b interface{}) bool {
+			return metric(a, b) == 0
+		}
+	}
+
+	dups := &duplicateIndex{
+		hash:   hash,
+		equal:  equal,
+		groups: make(map[uint64][][]interface{}),
+	}
+	unique := make([]interface{}, 0, len(items))
+
+next:
+	for _, item := range items {
+		h := hash(item)
+		groups := dups.groups[h]
+		for i, group := range groups {
+			if equal(item, group[0]) {
+				groups[i] = append(group, item)
+				continue next
+			}
+		}
+		dups.groups[h] = append(groups, []interface{}{item})
+		unique = append(unique, item)
+	}
+
+	t := New(metric, unique)
+	t.dups = dups
+	return t
+}
+
+// Duplicates returns all items that were collapsed into item when the tree was
+// built with NewDeduplicated, in their original order, starting with item
+// itself. It returns nil if item is not in the tree or the tree was not built
+// with NewDeduplicated.
+func (vp *VPTree) Duplicates(item interface{}) []interface{} {
+	if vp.dups == nil {
+		return nil
+	}
+	return append([]interface{}(nil), vp.dups.lookup(item)...)
+}
+
+// A duplicateIndex maps the items of a deduplicated tree to the groups of
+// duplicates they stand for; see NewDeduplicated.
+type duplicateIndex struct {
+	hash   func(item interface{}) uint64
+	equal  func(a, b interface{}) bool
+	groups map[uint64][][]interface{}
+}
+
+// lookup returns the group of duplicates of item, or nil if there is none.
+func (d *duplicateIndex) lookup(item interface{}) []interface{} {
+	for _, group := range d.groups[d.hash(item)] {
+		if d.equal(item, group[0]) {
+			return group
+		}
+	}
+	return nil
+}
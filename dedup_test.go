@@ -0,0 +1,155 @@
+package vptree
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// This test makes sure NewDeduplicated collapses equal items, but not items
+// whose hashes merely collide
+func TestNewDeduplicated(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 100; i++ {
+		// Every item appears three times
+		for j := 0; j < 3; j++ {
+			items = append(items, Coordinate{X: float64(i), Y: float64(i % 7)})
+		}
+	}
+
+	// A poor hash that makes many distinct items collide
+	hashCalls := 0
+	hash := func(item interface{}) uint64 {
+		hashCalls++
+		return uint64(item.(Coordinate).Y)
+	}
+	equal := func(a, b interface{}) bool {
+		return a.(Coordinate) == b.(Coordinate)
+	}
+
+	metricCalls := 0
+	metric := func(a, b interface{}) float64 {
+		metricCalls++
+		return CoordinateMetric(a, b)
+	}
+
+	vp := NewDeduplicated(metric, append([]interface{}(nil), items...), hash, equal)
+	if vp.size != 100 || len(vp.Items()) != 100 {
+		t.Errorf("Expected 100 unique items, got %v", len(vp.Items()))
+	}
+	if hashCalls != len(items) {
+		t.Errorf("Expected %v hash calls, got %v", len(items), hashCalls)
+	}
+
+	seen := make(map[Coordinate]bool)
+	for _, item := range vp.Items() {
+		if seen[item.(Coordinate)] {
+			t.Errorf("Expected %v to appear only once", item)
+		}
+		seen[item.(Coordinate)] = true
+	}
+
+	// Without equal, the metric decides
+	metricCalls = 0
+	vp = NewDeduplicated(metric, append([]interface{}(nil), items...), hash, nil)
+	if vp.size != 100 {
+		t.Errorf("Expected 100 unique items, got %v", vp.size)
+	}
+	if metricCalls == 0 {
+		t.Error("Expected the metric to decide between colliding items")
+	}
+
+	results, distances := vp.Search(Coordinate{X: 50, Y: 1}, 1)
+	if len(results) != 1 || distances[0] != 0 {
+		t.Errorf("Expected to find %v, got %v", Coordinate{X: 50, Y: 1}, results)
+	}
+}
+
+// This test makes sure a search near a collapsed item returns all of its
+// duplicates if asked to, still subject to K
+func TestExpandDuplicates(t *testing.T) {
+	type document struct {
+		Coordinate
+		ID int
+	}
+
+	var items []interface{}
+	for i := 0; i < 50; i++ {
+		for j := 0; j < 1+i%4; j++ {
+			items = append(items, document{Coordinate{X: float64(i), Y: 0}, len(items)})
+		}
+	}
+
+	metric := func(a, b interface{}) float64 {
+		return CoordinateMetric(a.(document).Coordinate, b.(document).Coordinate)
+	}
+	hash := func(item interface{}) uint64 {
+		return uint64(item.(document).X)
+	}
+	equal := func(a, b interface{}) bool {
+		return a.(document).Coordinate == b.(document).Coordinate
+	}
+	vp := NewDeduplicated(metric, append([]interface{}(nil), items...), hash, equal)
+
+	// 23 has been collapsed from 4 documents
+	q := document{Coordinate{X: 23.1, Y: 0}, -1}
+	results, _ := vp.SearchWithParameters(q, SearchParameters{K: 1})
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result without expanding, got %v", results)
+	}
+	dups := vp.Duplicates(results[0])
+	if len(dups) != 4 {
+		t.Fatalf("Expected 4 duplicates of %v, got %v", results[0], dups)
+	}
+
+	results, distances := vp.SearchWithParameters(q, SearchParameters{K: 10, MaxDistance: 0.5, ExpandDuplicates: true})
+	if len(results) != len(dups) {
+		t.Fatalf("Expected %v results, got %v", len(dups), results)
+	}
+	for i, item := range results {
+		if item != dups[i] {
+			t.Errorf("Expected results[%v] to be %v, got %v", i, dups[i], item)
+		}
+		if math.Abs(distances[i]-0.1) > 1e-9 {
+			t.Errorf("Expected distances[%v] to be 0.1, got %v", i, distances[i])
+		}
+	}
+
+	// K still limits the results: the 4 documents of 23, then the nearest
+	// other one
+	results, _ = vp.SearchWithParameters(q, SearchParameters{K: 5, ExpandDuplicates: true})
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %v", results)
+	}
+	for i := 0; i < 4; i++ {
+		if results[i] != dups[i] {
+			t.Errorf("Expected results[%v] to be %v, got %v", i, dups[i], results[i])
+		}
+	}
+	if x := results[4].(document).X; x != 24 {
+		t.Errorf("Expected the nearest other document next, got %v", results[4])
+	}
+	results, _ = vp.SearchWithParameters(q, SearchParameters{K: 2, ExpandDuplicates: true})
+	if len(results) != 2 || results[0] != dups[0] || results[1] != dups[1] {
+		t.Errorf("Expected the first 2 duplicates, got %v", results)
+	}
+
+	// SearchDistances expands the duplicates, too
+	p := SearchParameters{K: 6, ExpandDuplicates: true}
+	_, expected := vp.SearchWithParameters(q, p)
+	if distances := vp.SearchDistances(q, p); !reflect.DeepEqual(distances, expected) {
+		t.Errorf("Expected SearchDistances to return %v, got %v", expected, distances)
+	}
+
+	// Removing an item forgets the duplicates
+	if !vp.Remove(dups[0]) {
+		t.Fatalf("Expected %v to be removed", dups[0])
+	}
+	if d := vp.Duplicates(dups[0]); d != nil {
+		t.Errorf("Expected no duplicates after a removal, got %v", d)
+	}
+	results, _ = vp.SearchWithParameters(q, SearchParameters{K: 2, ExpandDuplicates: true})
+	if len(results) != 2 || results[0].(document).X == 23 {
+		t.Errorf("Expected the removed item and its duplicates to be gone, got %v", results)
+	}
+}
@@ -0,0 +1,107 @@
+package vptree
+
+// softKPoolFactor is the factor by which SearchSpread grows its pool of
+// candidates when the nearest neighbours are too clustered.
+const softKPoolFactor = 4
+
+// SearchDiverse searches for k neighbours of target that are spread out. It
+// takes the poolSize nearest neighbours of target as candidates and, in order
+// of their distance to target, picks every candidate that is at least
+// minSeparation away from all candidates picked so far. If that yields fewer
+// than k results, the nearest of the remaining candidates are added. The
+// results are returned in order of least distance to largest distance.
+func (vp *VPTree) SearchDiverse(target interface{}, k, poolSize int, minSeparation float64) (results []interface{}, distances []float64) {
+	results, distances, _ = vp.searchDiverse(target, k, poolSize, minSeparation)
+	return
+}
+
+// searchDiverse implements SearchDiverse and additionally returns how many of
+// the results are separated by at least minSeparation.
+func (vp *VPTree) searchDiverse(target interface{}, k, poolSize int, minSeparation float64) (results []interface{}, distances []float64, separated int) {
+	if k < 1 {
+		return
+	}
+	if poolSize < k {
+		poolSize = k
+	}
+
+	candidates, candidateDists := vp.Search(target, poolSize)
+	picked := make([]bool, len(candidates))
+
+	for i, c := range candidates {
+		if separated == k {
+			break
+		}
+
+		apart := true
+		for j := 0; j < i && apart; j++ {
+			if picked[j] && vp.distanceMetric(c, candidates[j]) < minSeparation {
+				apart = false
+			}
+		}
+
+		if apart {
+			picked[i] = true
+			separated++
+		}
+	}
+
+	// Fill up with the nearest candidates that were skipped
+	count := separated
+	for i := range candidates {
+		if count == k {
+			break
+		}
+		if !picked[i] {
+			picked[i] = true
+			count++
+		}
+	}
+
+	for i, c := range candidates {
+		if picked[i] {
+			results = append(results, c)
+			distances = append(distances, candidateDists[i])
+		}
+	}
+
+	return
+}
+
+// SearchSpread searches for the k nearest neighbours of target, but if their
+// mean pairwise distance is less than minSpread, it widens the search to
+// larger and larger pools of candidates until it can return k results that
+// are at least minSpread apart as SearchDiverse does (or the pool covers the
+// whole tree). This trades some closeness to the target for results that are
+// not all clumped together.
+func (vp *VPTree) SearchSpread(target interface{}, k int, minSpread float64) (results []interface{}, distances []float64) {
+	results, distances = vp.Search(target, k)
+
+	if len(results) < 2 || vp.spread(results) >= minSpread {
+		return
+	}
+
+	for pool := softKPoolFactor * k; ; pool *= softKPoolFactor {
+		var separated int
+		results, distances, separated = vp.searchDiverse(target, k, pool, minSpread)
+		if separated == len(results) || pool >= vp.size {
+			return
+		}
+	}
+}
+
+// spread returns the mean pairwise distance between items.
+func (vp *VPTree) spread(items []interface{}) float64 {
+	if len(items) < 2 {
+		return 0
+	}
+
+	sum := 0.0
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			sum += vp.distanceMetric(items[i], items[j])
+		}
+	}
+
+	return sum / float64(len(items)*(len(items)-1)/2)
+}
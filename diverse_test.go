@@ -0,0 +1,73 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func newClusteredCoordinateTree() *VPTree {
+	var vpitems []interface{}
+
+	// A tight cluster around the origin
+	for i := 0; i < 50; i++ {
+		vpitems = append(vpitems, Coordinate{X: rand.Float64() * 0.01, Y: rand.Float64() * 0.01})
+	}
+
+	// A sparse grid around it
+	for x := -5; x <= 5; x++ {
+		for y := -5; y <= 5; y++ {
+			if x != 0 || y != 0 {
+				vpitems = append(vpitems, Coordinate{float64(x) / 10, float64(y) / 10})
+			}
+		}
+	}
+
+	return New(CoordinateMetric, vpitems)
+}
+
+// This test makes sure SearchDiverse keeps its results apart
+func TestSearchDiverse(t *testing.T) {
+	vp := newClusteredCoordinateTree()
+
+	results, distances := vp.SearchDiverse(Coordinate{0, 0}, 5, 200, 0.05)
+	if len(results) != 5 {
+		t.Fatalf("Expected 5 results, got %v", len(results))
+	}
+
+	for i := range results {
+		if CoordinateMetric(results[i], Coordinate{0, 0}) != distances[i] {
+			t.Errorf("Expected distances[%v] to be the distance to the target", i)
+		}
+		if i > 0 && distances[i] < distances[i-1] {
+			t.Errorf("Expected distances to be ascending, got %v", distances)
+		}
+		for j := 0; j < i; j++ {
+			if CoordinateMetric(results[i], results[j]) < 0.05 {
+				t.Errorf("Expected %v and %v to be at least 0.05 apart", results[i], results[j])
+			}
+		}
+	}
+}
+
+// This test makes sure SearchSpread widens clustered results, but leaves
+// well-spread results alone
+func TestSearchSpread(t *testing.T) {
+	vp := newClusteredCoordinateTree()
+	q := Coordinate{0, 0}
+
+	plain, _ := vp.Search(q, 5)
+	spread, _ := vp.SearchSpread(q, 5, 0.05)
+
+	if len(spread) != 5 {
+		t.Fatalf("Expected 5 results, got %v", len(spread))
+	}
+	if vp.spread(spread) <= vp.spread(plain) {
+		t.Errorf("Expected SearchSpread results to be more spread out than %v, got %v", vp.spread(plain), vp.spread(spread))
+	}
+
+	// Away from the cluster, the nearest neighbours are spread out already
+	q = Coordinate{0.42, -0.37}
+	plain, plainDists := vp.Search(q, 5)
+	spread, spreadDists := vp.SearchSpread(q, 5, 0.05)
+	compareResults(t, spread, spreadDists, plain, plainDists)
+}
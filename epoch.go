@@ -0,0 +1,11 @@
+package vptree
+
+// Epoch returns a counter that is incremented by every modification of the
+// VP-tree: once per inserted or removed item, and once per Rebuild, Compact
+// and Reindex. Searches do not change it. Caches of search results can store
+// the epoch alongside the results and discard them once the epoch changes.
+// Epochs start at 0 and are only comparable between a tree and the trees it
+// was derived from.
+func (vp *VPTree) Epoch() uint64 {
+	return vp.epoch
+}
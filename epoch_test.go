@@ -0,0 +1,65 @@
+package vptree
+
+import "testing"
+
+// This test makes sure every modification increments the epoch exactly once
+// and searches leave it alone
+func TestEpoch(t *testing.T) {
+	vp := newRandomCoordinateTree(100)
+
+	expected := uint64(0)
+	check := func(what string) {
+		t.Helper()
+		if epoch := vp.Epoch(); epoch != expected {
+			t.Errorf("%v: expected epoch %v, got %v", what, expected, epoch)
+		}
+	}
+	check("New")
+
+	q := Coordinate{0.5, 0.5}
+	vp.Search(q, 10)
+	vp.SearchRadius(q, 0.2)
+	vp.Nearest(q, nil)
+	check("Search")
+
+	vp.Insert(q)
+	expected++
+	check("Insert")
+
+	vp.Remove(q)
+	expected++
+	check("Remove")
+
+	vp.Remove(q)
+	check("Remove of a missing item")
+
+	vp.Rebuild()
+	expected++
+	check("Rebuild")
+
+	vp.Compact()
+	expected++
+	check("Compact")
+
+	vp.Reindex()
+	expected++
+	check("Reindex")
+
+	vp.PopNearestK(q, 3)
+	expected += 3
+	check("PopNearestK")
+
+	c := NewConcurrent(vp)
+	defer c.Close()
+	c.Insert(q)
+	c.Insert(Coordinate{0.1, 0.1})
+	if epoch := c.Epoch(); epoch != expected+2 {
+		t.Errorf("expected concurrent epoch %v, got %v", expected+2, epoch)
+	}
+	if err := <-c.RebuildBackground(nil); err != nil {
+		t.Fatal(err)
+	}
+	if epoch := c.Epoch(); epoch != expected+3 {
+		t.Errorf("expected epoch %v after a background rebuild, got %v", expected+3, epoch)
+	}
+}
@@ -0,0 +1,117 @@
+package vptree
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// executorMinItems is the size below which NewWithExecutor builds a subtree
+// in a single task instead of splitting it into more tasks.
+const executorMinItems = 256
+
+// NewWithExecutor is like New, but builds the tree in tasks that it schedules
+// by passing them to submit. This lets applications run the build on a
+// worker pool they already manage, e.g. with
+//
+//	NewWithExecutor(metric, items, func(task func()) { pool.Go(task) })
+//
+// submit may run the task synchronously or on any goroutine, and may block
+// until a worker is free: tasks never wait for each other or call submit
+// themselves. NewWithExecutor returns once all tasks have finished.
+func NewWithExecutor(metric Metric, items []interface{}, submit func(task func())) (t *VPTree) {
+	t = newVPTree(metric, len(items))
+	t.rnd = rand.New(&lockedSource{src: t.src})
+	t.root = t.buildWithExecutor(items, submit)
+	return
+}
+
+func (vp *VPTree) buildWithExecutor(items []interface{}, submit func(task func())) *node {
+	var root *node
+
+	// Tasks hand the subtrees that are left to build back to this
+	// goroutine instead of submitting them themselves, so that they never
+	// block in submit while occupying a worker.
+	q := &buildQueue{jobs: []buildJob{{&root, items, 0}}}
+	q.cond = sync.NewCond(&q.mu)
+
+	q.mu.Lock()
+	for {
+		for len(q.jobs) == 0 && q.running > 0 {
+			q.cond.Wait()
+		}
+		if len(q.jobs) == 0 {
+			break
+		}
+
+		job := q.jobs[len(q.jobs)-1]
+		q.jobs = q.jobs[:len(q.jobs)-1]
+		q.running++
+		q.mu.Unlock()
+
+		submit(func() {
+			jobs := vp.runBuildJob(job)
+
+			q.mu.Lock()
+			q.jobs = append(q.jobs, jobs...)
+			q.running--
+			q.mu.Unlock()
+			q.cond.Signal()
+		})
+
+		q.mu.Lock()
+	}
+	q.mu.Unlock()
+
+	return root
+}
+
+// A buildJob is a subtree that is yet to be built and stored in *dst.
+type buildJob struct {
+	dst   **node
+	items []interface{}
+	depth int
+}
+
+// buildQueue holds the build jobs that are yet to be submitted and counts
+// the ones that are running.
+type buildQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	jobs    []buildJob
+	running int
+}
+
+// runBuildJob builds small subtrees completely. For large subtrees, it only
+// builds the root and returns the jobs for its subtrees, in reverse order so
+// that a serial executor builds the tree in the same order as New.
+func (vp *VPTree) runBuildJob(job buildJob) []buildJob {
+	if len(job.items) < executorMinItems {
+		*job.dst = vp.buildFromPoints(job.items, job.depth)
+		return nil
+	}
+
+	n, left, right := vp.buildNode(job.items, job.depth)
+	*job.dst = n
+	return []buildJob{
+		{&n.Right, right, job.depth + 1},
+		{&n.Left, left, job.depth + 1},
+	}
+}
+
+// lockedSource is a rand.Source that is safe for concurrent use.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,69 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// sameTree reports whether the subtrees rooted at a and b have the same shape,
+// items and thresholds
+func sameTree(a, b *node) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Item == b.Item && a.Threshold == b.Threshold &&
+		sameTree(a.Left, b.Left) && sameTree(a.Right, b.Right)
+}
+
+// This test makes sure a serial executor builds the same tree as New
+func TestExecutorSerial(t *testing.T) {
+	items := make([]interface{}, 5000)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	sequential := newVPTree(CoordinateMetric, len(items))
+	sequential.rnd = rand.New(rand.NewSource(1))
+	sequential.root = sequential.buildFromPoints(append([]interface{}(nil), items...), 0)
+
+	serial := newVPTree(CoordinateMetric, len(items))
+	serial.rnd = rand.New(&lockedSource{src: rand.NewSource(1)})
+	serial.root = serial.buildWithExecutor(append([]interface{}(nil), items...), func(task func()) {
+		task()
+	})
+
+	if !sameTree(sequential.root, serial.root) {
+		t.Error("Expected a serial executor to build the same tree as New")
+	}
+}
+
+// This test makes sure a concurrent executor builds a correct tree
+func TestExecutorConcurrent(t *testing.T) {
+	items := make([]interface{}, 5000)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	// A bounded pool of four workers
+	sem := make(chan struct{}, 4)
+	vp := NewWithExecutor(CoordinateMetric, append([]interface{}(nil), items...), func(task func()) {
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem }()
+			task()
+		}()
+	})
+
+	if n := len(vp.Items()); n != len(items) {
+		t.Fatalf("Expected %v items, got %v", len(items), n)
+	}
+	checkRadii(t, vp)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		results, distances := vp.Search(q, 10)
+		expected, expectedDists := BruteForceSearch(CoordinateMetric, items, q, 10)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+}
@@ -0,0 +1,139 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+)
+
+// Medoid returns the item with the smallest sum of distances to all other
+// items, or nil if the tree is empty. It evaluates the metric for every pair
+// of items, so it is expensive for large trees.
+func (vp *VPTree) Medoid() interface{} {
+	items := vp.Items()
+
+	var medoid interface{}
+	best := math.Inf(1)
+
+	for _, a := range items {
+		sum := 0.0
+		for _, b := range items {
+			sum += vp.distanceMetric(a, b)
+			if sum >= best {
+				break
+			}
+		}
+		if sum < best {
+			medoid, best = a, sum
+		}
+	}
+
+	return medoid
+}
+
+// ExtremePoints returns the up to n items that are farthest away from the
+// medoid of the tree, in order of largest distance to least distance. These
+// are the items on the boundary of the dataset, which makes ExtremePoints
+// useful for finding outliers in metric spaces without coordinates.
+func (vp *VPTree) ExtremePoints(n int) []interface{} {
+	if vp.root == nil {
+		return nil
+	}
+
+	extremes, _ := vp.SearchFarthest(vp.Medoid(), n)
+	return extremes
+}
+
+// BoundingSphere returns an item near the center of the dataset, its medoid,
+// and the distance from it to the farthest item, so that all items lie within
+// radius of center. This summarizes the extent of the dataset, e.g. to choose
+// query radii or to scale a visualization. Since the center has to be an
+// item, radius may be larger than that of the smallest enclosing sphere, but
+// at most twice as large, by the triangle inequality. center is nil if the
+// tree is empty.
+//
+// Finding the medoid evaluates the metric for every pair of items, so this is
+// expensive for large trees.
+func (vp *VPTree) BoundingSphere() (center interface{}, radius float64) {
+	if vp.root == nil {
+		return nil, 0
+	}
+
+	center = vp.Medoid()
+	if _, distances := vp.SearchFarthest(center, 1); len(distances) > 0 {
+		radius = distances[0]
+	}
+
+	return center, radius
+}
+
+// MedianPairwiseDistance estimates the median distance between two distinct
+// items of the tree from the given number of random pairs. This
+// characterizes the scale of the dataset, e.g. to choose a sensible
+// MaxDistance, without evaluating the metric for every pair of items. It
+// returns 0 if the tree has fewer than two items or samples is not positive.
+func (vp *VPTree) MedianPairwiseDistance(samples int) float64 {
+	items := vp.Items()
+	if len(items) < 2 || samples < 1 {
+		return 0
+	}
+
+	dists := make([]float64, samples)
+	for i := range dists {
+		a := vp.rnd.Intn(len(items))
+		b := vp.rnd.Intn(len(items) - 1)
+		if b >= a {
+			b++
+		}
+		dists[i] = vp.distanceMetric(items[a], items[b])
+	}
+	sort.Float64s(dists)
+
+	if samples%2 == 1 {
+		return dists[samples/2]
+	}
+	return (dists[samples/2-1] + dists[samples/2]) / 2
+}
+
+// problematicSpread is the standard deviation, relative to the mean, below
+// which ProblematicItems considers an item's distances to be all the same.
+const problematicSpread = 0.01
+
+// ProblematicItems returns the items whose distances to a random sample of
+// sampleSize items hardly vary, in no particular order. Such items, e.g. a
+// zero vector under cosine distance or a point exactly at the center of a
+// sphere, are equidistant from large groups of items, so they neither make
+// good vantage points nor end up on a well-defined side of one, which leads
+// to skewed partitions. An item is flagged if the standard deviation of its
+// distances is less than 1% of their mean. The sample is drawn the same way
+// as by SelectorSpread, ignoring distances of 0, and the metric is evaluated
+// sampleSize times for every item.
+func (vp *VPTree) ProblematicItems(sampleSize int) []interface{} {
+	items := vp.Items()
+	if len(items) < 2 || sampleSize < 1 {
+		return nil
+	}
+
+	sample := sampleItems(items, sampleSize, vp.rnd)
+
+	var problematic []interface{}
+	for _, item := range items {
+		// Distances of 0 are to the item itself or its duplicates, which
+		// say nothing about how well it discriminates the others.
+		var n, sum, sumSq float64
+		for _, s := range sample {
+			if d := vp.distanceMetric(item, s); d != 0 {
+				n, sum, sumSq = n+1, sum+d, sumSq+d*d
+			}
+		}
+		if n == 0 {
+			continue
+		}
+
+		mean := sum / n
+		if sumSq/n-mean*mean <= problematicSpread*problematicSpread*mean*mean {
+			problematic = append(problematic, item)
+		}
+	}
+
+	return problematic
+}
@@ -0,0 +1,234 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test compares SearchFarthest against a brute-force search
+func TestSearchFarthest(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		k := rand.Intn(20) + 1
+
+		expected := make([]float64, len(items))
+		for j, v := range items {
+			expected[j] = CoordinateMetric(q, v)
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(expected)))
+
+		results, distances := vp.SearchFarthest(q, k)
+		if len(results) != k {
+			t.Fatalf("Expected %v results, got %v", k, len(results))
+		}
+		for j := range distances {
+			if distances[j] != expected[j] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, expected[j], distances[j])
+			}
+			if CoordinateMetric(q, results[j]) != distances[j] {
+				t.Errorf("Expected results[%v] to be at distance %v", j, distances[j])
+			}
+		}
+	}
+}
+
+// This test makes sure ExtremePoints finds outliers that were planted far
+// away from a dense cluster
+func TestExtremePoints(t *testing.T) {
+	extremes := []Coordinate{
+		Coordinate{0, -12},
+		Coordinate{10, 10},
+		Coordinate{-10, 5},
+	}
+
+	var vpitems []interface{}
+	for i := 0; i < 500; i++ {
+		vpitems = append(vpitems, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	for _, e := range extremes {
+		vpitems = append(vpitems, e)
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	actual := vp.ExtremePoints(3)
+	if len(actual) != len(extremes) {
+		t.Fatalf("Expected %v extreme points, got %v", len(extremes), len(actual))
+	}
+	for _, e := range extremes {
+		found := false
+		for _, a := range actual {
+			if a == e {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %v to be among the extreme points %v", e, actual)
+		}
+	}
+
+	if New(CoordinateMetric, nil).ExtremePoints(3) != nil {
+		t.Error("Expected no extreme points for an empty tree")
+	}
+}
+
+// This test makes sure Medoid picks the most central item
+func TestMedoid(t *testing.T) {
+	vpitems := []interface{}{
+		Coordinate{0, 0},
+		Coordinate{1, 0},
+		Coordinate{2, 0},
+		Coordinate{3, 0},
+		Coordinate{10, 0},
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	if m := vp.Medoid(); m != (Coordinate{2, 0}) {
+		t.Errorf("Expected medoid {2 0}, got %v", m)
+	}
+}
+
+// This test makes sure BoundingSphere encloses all items, and is at most twice
+// as large as the smallest enclosing sphere
+func TestBoundingSphere(t *testing.T) {
+	// The smallest enclosing circle is the unit circle, through three of
+	// the items
+	items := []interface{}{
+		Coordinate{1, 0},
+		Coordinate{math.Cos(2 * math.Pi / 3), math.Sin(2 * math.Pi / 3)},
+		Coordinate{math.Cos(4 * math.Pi / 3), math.Sin(4 * math.Pi / 3)},
+	}
+	for i := 0; i < 500; i++ {
+		r, phi := math.Sqrt(rand.Float64()), rand.Float64()*2*math.Pi
+		items = append(items, Coordinate{X: r * math.Cos(phi), Y: r * math.Sin(phi)})
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	center, radius := vp.BoundingSphere()
+	if center != vp.Medoid() {
+		t.Errorf("expected the medoid %v as the center, got %v", vp.Medoid(), center)
+	}
+	for _, item := range items {
+		if d := CoordinateMetric(center, item); d > radius {
+			t.Errorf("expected %v to be within %v of %v, got %v", item, radius, center, d)
+		}
+	}
+	if radius < 1 || radius > 2 {
+		t.Errorf("expected a radius between 1 and 2, got %v", radius)
+	}
+
+	if center, radius := New(CoordinateMetric, nil).BoundingSphere(); center != nil || radius != 0 {
+		t.Errorf("expected no sphere for an empty tree, got %v and %v", center, radius)
+	}
+}
+
+// This test makes sure MedianPairwiseDistance is close to the true median
+// distance between all pairs of items
+func TestMedianPairwiseDistance(t *testing.T) {
+	items := make([]interface{}, 500)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	var all []float64
+	for i := range items {
+		for j := i + 1; j < len(items); j++ {
+			all = append(all, CoordinateMetric(items[i], items[j]))
+		}
+	}
+	sort.Float64s(all)
+	expected := all[len(all)/2]
+
+	vp := New(CoordinateMetric, items)
+
+	median := vp.MedianPairwiseDistance(5000)
+	if math.Abs(median-expected) > 0.05*expected {
+		t.Errorf("Expected a median close to %v, got %v", expected, median)
+	}
+
+	if d := New(CoordinateMetric, items[:1]).MedianPairwiseDistance(10); d != 0 {
+		t.Errorf("Expected 0 for a single item, got %v", d)
+	}
+	if d := vp.MedianPairwiseDistance(0); d != 0 {
+		t.Errorf("Expected 0 for no samples, got %v", d)
+	}
+}
+
+// This test makes sure every item in FarthestFirstOrder is the farthest away
+// from the items before it
+func TestFarthestFirstOrder(t *testing.T) {
+	items := make([]interface{}, 300)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	order := vp.FarthestFirstOrder()
+	if len(order) != len(items) {
+		t.Fatalf("Expected %v items, got %v", len(items), len(order))
+	}
+
+	// minDist holds the distance of every item to the prefix of the order
+	minDist := make(map[interface{}]float64)
+	for _, item := range items {
+		minDist[item] = math.Inf(1)
+	}
+
+	for i, item := range order {
+		if i > 0 {
+			farthest := 0.0
+			for _, d := range minDist {
+				farthest = math.Max(farthest, d)
+			}
+			if minDist[item] != farthest {
+				t.Fatalf("Expected item %v to be %v away from the items before it, got %v", i, farthest, minDist[item])
+			}
+		}
+
+		delete(minDist, item)
+		for other, d := range minDist {
+			minDist[other] = math.Min(d, CoordinateMetric(item, other))
+		}
+	}
+
+	if order := New(CoordinateMetric, nil).FarthestFirstOrder(); len(order) != 0 {
+		t.Errorf("Expected no items for an empty tree, got %v", order)
+	}
+}
+
+func TestProblematicItems(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	// Points on the unit circle, and its center, which is at distance 1
+	// from all of them
+	items := []interface{}{Vector{0, 0}}
+	for i := 0; i < 500; i++ {
+		a := r.Float64() * 2 * math.Pi
+		items = append(items, Vector{math.Cos(a), math.Sin(a)})
+	}
+	vp := New(VectorMetric, items)
+
+	problematic := vp.ProblematicItems(32)
+	if len(problematic) != 1 {
+		t.Fatalf("expected 1 problematic item, got %v", len(problematic))
+	}
+	if v := problematic[0].(Vector); v[0] != 0 || v[1] != 0 {
+		t.Errorf("expected the center to be flagged, got %v", v)
+	}
+
+	if problematic := New(VectorMetric, nil).ProblematicItems(32); problematic != nil {
+		t.Errorf("expected no problematic items in an empty tree, got %v", problematic)
+	}
+}
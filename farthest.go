@@ -0,0 +1,127 @@
+package vptree
+
+import (
+	"container/heap"
+	"math"
+)
+
+// SearchFarthest searches the VP-tree for the k items farthest away from
+// target. It returns the up to k farthest items and the corresponding
+// distances in order of largest distance to least distance.
+//
+// Subtrees are pruned using their bounding radii, which are usually looser
+// than the thresholds Search prunes with, so SearchFarthest usually visits
+// more nodes than Search.
+func (vp *VPTree) SearchFarthest(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	// The heap stores negated distances, so that its top is the closest of
+	// the farthest items found so far.
+	h := make(priorityQueue, 0, k)
+	vp.searchFarthest(vp.root, target, k, &h)
+
+	for h.Len() > 0 {
+		hi := heap.Pop(&h).(*heapItem)
+		results = append(results, hi.Item)
+		distances = append(distances, -hi.Dist)
+	}
+
+	// Reverse results and distances, because we popped them from the heap
+	// in small-to-large order
+	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+		results[i], results[j] = results[j], results[i]
+		distances[i], distances[j] = distances[j], distances[i]
+	}
+
+	return
+}
+
+func (vp *VPTree) searchFarthest(n *node, target interface{}, k int, h *priorityQueue) {
+	if n == nil {
+		return
+	}
+
+	dist := vp.distanceMetric(n.Item, target)
+
+	tau := math.Inf(-1)
+	if h.Len() == k {
+		tau = -h.Top().(*heapItem).Dist
+	}
+
+	if dist > tau {
+		if h.Len() == k {
+			heap.Pop(h)
+		}
+		heap.Push(h, &heapItem{n.Item, -dist, n})
+	}
+
+	for _, b := range n.Bucket {
+		vp.searchFarthest(b, target, k, h)
+	}
+
+	// No item in a subtree is farther away than dist plus its radius
+	if h.Len() < k || dist+n.RightRadius > -h.Top().(*heapItem).Dist {
+		vp.searchFarthest(n.Right, target, k, h)
+	}
+
+	leftBound := math.Min(vp.leftBound(n), n.LeftRadius)
+	if h.Len() < k || dist+leftBound > -h.Top().(*heapItem).Dist {
+		vp.searchFarthest(n.Left, target, k, h)
+	}
+}
+
+// FarthestFirstOrder returns all items of the VP-tree in farthest-first
+// traversal order: starting from an arbitrary item, every following item is
+// the one farthest away from all items before it. The first k items are a
+// greedy solution to the k-center problem, which makes them a good coreset
+// or a well-spread sample of the data.
+//
+// Whenever an item is chosen, only the items that are now closer to it than
+// to the items chosen before need to be updated, and these are found with a
+// radius search of the tree. Since the radius shrinks as the traversal goes
+// on, this usually takes far fewer than the n^2 metric evaluations of the
+// naive algorithm.
+func (vp *VPTree) FarthestFirstOrder() []interface{} {
+	if vp.root == nil {
+		return nil
+	}
+
+	// dist holds the distance of every node not yet chosen to the chosen
+	// items. The heap holds the candidates for the next item, some of
+	// which may be outdated.
+	dist := make(map[*node]float64, vp.size)
+	var h priorityQueue
+
+	order := []interface{}{vp.root.Item}
+	vp.root.walk(func(n *node) {
+		if n != vp.root {
+			dist[n] = vp.distanceMetric(n.Item, vp.root.Item)
+			h = append(h, &heapItem{n.Item, dist[n], n})
+		}
+	})
+	heap.Init(&h)
+
+	for h.Len() > 0 {
+		hi := heap.Pop(&h).(*heapItem)
+		if d, ok := dist[hi.node]; !ok || d != hi.Dist {
+			continue
+		}
+
+		chosen := hi.node
+		order = append(order, chosen.Item)
+		delete(dist, chosen)
+
+		// All remaining items are at most hi.Dist away from the chosen
+		// items, so only those this close to the new item can get closer.
+		vp.withinRadius(vp.root, chosen.Item, hi.Dist, func(n *node, d float64) {
+			if old, ok := dist[n]; ok && d < old {
+				dist[n] = d
+				heap.Push(&h, &heapItem{n.Item, d, n})
+			}
+		})
+	}
+
+	return order
+}
@@ -0,0 +1,190 @@
+package vptree
+
+import (
+	"container/heap"
+	"math/rand"
+)
+
+// A Distance is a type that distances can be measured in: an integer or
+// floating-point type, or a custom type whose underlying type is one, such as
+//
+//	type Fixed int64 // distance in thousandths
+//
+// Types whose underlying type is not numeric, e.g. structs, are not
+// supported. Searches only compare distances and subtract the smaller of two
+// distances from the larger one, which cannot overflow, since a metric never
+// returns negative distances, so even uint8 distances can use their whole
+// range. Searches do not need a maximum value of the type either, because
+// they keep track of whether they have a search radius yet instead of
+// starting with an infinite one.
+type Distance interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// A GenericMetric is like Metric, but measures the distance between two
+// items of type T in the distance type D.
+type GenericMetric[T interface{}, D Distance] func(a, b T) D
+
+// A Tree is a Vantage-point tree like VPTree, but for items of type T and
+// distances of type D. This avoids type assertions in the metric and lets
+// metrics use integer or other non-float64 distances.
+type Tree[T interface{}, D Distance] struct {
+	root   *genericNode[T, D]
+	metric GenericMetric[T, D]
+	rnd    *rand.Rand
+}
+
+type genericNode[T interface{}, D Distance] struct {
+	item      T
+	threshold D
+	left      *genericNode[T, D]
+	right     *genericNode[T, D]
+}
+
+// NewTree creates a new Tree using the metric and items provided, like New.
+func NewTree[T interface{}, D Distance](metric GenericMetric[T, D], items []T) *Tree[T, D] {
+	t := &Tree[T, D]{
+		metric: metric,
+		rnd:    rand.New(rand.NewSource(rand.Int63())),
+	}
+	t.root = t.build(items)
+	return t
+}
+
+func (t *Tree[T, D]) build(items []T) *genericNode[T, D] {
+	if len(items) == 0 {
+		return nil
+	}
+
+	n := &genericNode[T, D]{}
+
+	idx := t.rnd.Intn(len(items))
+	n.item = items[idx]
+	items[idx], items = items[len(items)-1], items[:len(items)-1]
+
+	if len(items) == 0 {
+		return n
+	}
+
+	// Partition the items around the median, like VPTree.partition
+	median := len(items) / 2
+	pivotDist := t.metric(items[median], n.item)
+	items[median], items[len(items)-1] = items[len(items)-1], items[median]
+
+	storeIndex := 0
+	for i := 0; i < len(items)-1; i++ {
+		if t.metric(items[i], n.item) <= pivotDist {
+			items[storeIndex], items[i] = items[i], items[storeIndex]
+			storeIndex++
+		}
+	}
+	items[len(items)-1], items[storeIndex] = items[storeIndex], items[len(items)-1]
+
+	n.threshold = pivotDist
+	n.left = t.build(items[:storeIndex])
+	n.right = t.build(items[storeIndex:])
+	return n
+}
+
+// Search searches the Tree for the k nearest neighbours of target, like
+// VPTree.Search.
+func (t *Tree[T, D]) Search(target T, k int) (results []T, distances []D) {
+	if k < 1 {
+		return
+	}
+
+	s := &genericSearcher[T, D]{t: t, target: target, k: k}
+	s.search(t.root)
+
+	results = make([]T, s.h.Len())
+	distances = make([]D, s.h.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		hi := heap.Pop(&s.h).(genericHeapItem[T, D])
+		results[i], distances[i] = hi.item, hi.dist
+	}
+
+	return
+}
+
+type genericSearcher[T interface{}, D Distance] struct {
+	t      *Tree[T, D]
+	target T
+	k      int
+	h      genericQueue[T, D]
+}
+
+func (s *genericSearcher[T, D]) search(n *genericNode[T, D]) {
+	if n == nil {
+		return
+	}
+
+	dist := s.t.metric(n.item, s.target)
+
+	// tau is only defined once the heap is full
+	full := s.h.Len() == s.k
+	if !full || dist < s.h.top().dist {
+		if full {
+			heap.Pop(&s.h)
+		}
+		heap.Push(&s.h, genericHeapItem[T, D]{n.item, dist})
+	}
+
+	if n.left == nil && n.right == nil {
+		return
+	}
+
+	// The same pruning rules as in VPTree, rearranged so that they only
+	// subtract the smaller distance from the larger one, which can neither
+	// overflow nor underflow
+	searchLeft := func() bool {
+		return s.h.Len() < s.k || dist <= n.threshold || dist-n.threshold <= s.h.top().dist
+	}
+	searchRight := func() bool {
+		return s.h.Len() < s.k || dist >= n.threshold || n.threshold-dist <= s.h.top().dist
+	}
+
+	if dist < n.threshold {
+		if searchLeft() {
+			s.search(n.left)
+		}
+		if searchRight() {
+			s.search(n.right)
+		}
+	} else {
+		if searchRight() {
+			s.search(n.right)
+		}
+		if searchLeft() {
+			s.search(n.left)
+		}
+	}
+}
+
+type genericHeapItem[T interface{}, D Distance] struct {
+	item T
+	dist D
+}
+
+// genericQueue is a max-heap like priorityQueue.
+type genericQueue[T interface{}, D Distance] []genericHeapItem[T, D]
+
+func (q genericQueue[T, D]) Len() int { return len(q) }
+
+func (q genericQueue[T, D]) Less(i, j int) bool { return q[i].dist > q[j].dist }
+
+func (q genericQueue[T, D]) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *genericQueue[T, D]) Push(i interface{}) {
+	*q = append(*q, i.(genericHeapItem[T, D]))
+}
+
+func (q *genericQueue[T, D]) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+func (q genericQueue[T, D]) top() genericHeapItem[T, D] { return q[0] }
@@ -0,0 +1,96 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// fixed is a fixed-point distance type in thousandths
+type fixed int64
+
+// checkGenericSearch compares the results of Tree.Search against the sorted
+// distances from target to all items
+func checkGenericSearch[T interface{}, D Distance](t *testing.T, metric GenericMetric[T, D], items []T, target T, k int) {
+	expected := make([]D, len(items))
+	for i, item := range items {
+		expected[i] = metric(item, target)
+	}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	if k < len(expected) {
+		expected = expected[:k]
+	}
+
+	tree := NewTree(metric, append([]T(nil), items...))
+	results, distances := tree.Search(target, k)
+
+	if len(results) != len(expected) {
+		t.Fatalf("Expected %v results, got %v", len(expected), len(results))
+	}
+	for i := range expected {
+		if distances[i] != expected[i] {
+			t.Errorf("Expected distances[%v] to be %v, got %v", i, expected[i], distances[i])
+		}
+		if metric(results[i], target) != distances[i] {
+			t.Errorf("Expected results[%v] to be %v away, got %v", i, distances[i], metric(results[i], target))
+		}
+	}
+}
+
+// This test makes sure Tree finds the nearest neighbours with integer,
+// float32 and fixed-point distances
+func TestGenericTree(t *testing.T) {
+	ints := make([]int, 1000)
+	for i := range ints {
+		ints[i] = rand.Intn(10000)
+	}
+	intMetric := func(a, b int) uint {
+		if a < b {
+			return uint(b - a)
+		}
+		return uint(a - b)
+	}
+
+	type point struct{ x, y float32 }
+	points := make([]point, 1000)
+	for i := range points {
+		points[i] = point{rand.Float32(), rand.Float32()}
+	}
+	float32Metric := func(a, b point) float32 {
+		return float32(math.Hypot(float64(a.x-b.x), float64(a.y-b.y)))
+	}
+	fixedMetric := func(a, b point) fixed {
+		return fixed(math.Round(1000 * math.Hypot(float64(a.x-b.x), float64(a.y-b.y))))
+	}
+
+	for i := 0; i < 10; i++ {
+		k := rand.Intn(20) + 1
+		checkGenericSearch(t, intMetric, ints, rand.Intn(10000), k)
+
+		target := point{rand.Float32(), rand.Float32()}
+		checkGenericSearch(t, float32Metric, points, target, k)
+		checkGenericSearch(t, fixedMetric, points, target, k)
+	}
+
+	// Sums of uint8 distances overflow
+	bytes := make([]uint8, 256)
+	for i := range bytes {
+		bytes[i] = uint8(i)
+	}
+	byteMetric := func(a, b uint8) uint8 {
+		if a < b {
+			return b - a
+		}
+		return a - b
+	}
+	for _, target := range []uint8{0, 1, 127, 200, 255} {
+		for _, k := range []int{1, 10, 100, 200} {
+			checkGenericSearch(t, byteMetric, bytes, target, k)
+		}
+	}
+
+	if results, _ := NewTree(intMetric, nil).Search(0, 3); len(results) != 0 {
+		t.Errorf("Expected no results from an empty tree, got %v", results)
+	}
+}
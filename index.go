@@ -0,0 +1,447 @@
+package vptree
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// indexMagic identifies files written by WriteIndex.
+var indexMagic = [4]byte{'V', 'P', 'T', 'I'}
+
+const (
+	indexVersion          = 1
+	indexVersionQuantized = 2
+	indexHeaderSize       = 24
+	indexNodeSize         = 40
+
+	// Quantized indexes also store the grid of their thresholds in the
+	// header; see WriteIndex.
+	indexQuantizedHeaderSize = 40
+)
+
+// An IndexLayout is an order in which WriteIndexWithLayout stores the nodes of
+// a VP-tree.
+type IndexLayout int
+
+const (
+	// LayoutPreOrder stores the nodes in pre-order, i.e. every node is
+	// followed by its left and then its right subtree. This is what
+	// WriteIndex does.
+	LayoutPreOrder IndexLayout = iota
+
+	// LayoutVanEmdeBoas stores the nodes in van Emde Boas order: the top
+	// half of the levels of the tree is stored first, recursively in the
+	// same order, followed by every subtree below it, each in turn. Every
+	// path from the root then touches few pages and cache lines of the
+	// index, whatever their size, which helps searches that spend most of
+	// their time near the top of the tree.
+	//
+	// In BenchmarkIndexLayout, with an index of 200,000 items that is
+	// entirely in the page cache, both layouts are equally fast. The van
+	// Emde Boas layout is meant for indexes that are much larger than the
+	// memory or the CPU caches; measure on your own data.
+	LayoutVanEmdeBoas
+)
+
+// WriteIndex writes the VP-tree to w in a compact binary format that
+// OpenMmapIndex can search without loading it into memory. Every item is
+// stored as a record of recordSize bytes, which encode fills in.
+//
+// The file starts with a 24-byte header of the magic bytes "VPTI", the format
+// version, the record size (both uint32), 4 bytes of padding and the number
+// of nodes (uint64).
+// It is followed by a table with one 40-byte entry per node: the upper bound
+// of the distances in its left subtree, the lower and upper bound of the
+// distances in its right subtree (float64 each), the indices of its left and
+// right child (int32, -1 for none), and the index and number of its bucket
+// nodes (uint32 each). The records of the items follow in the same order.
+// All numbers are little-endian, the root is node 0, and the children and
+// bucket nodes of every node come after it.
+//
+// Trees built with NewQuantized are written in format version 2, which
+// stores the lower bound of the distances in the right subtree, i.e. the
+// threshold, as its code of 1 or 2 bytes instead. The header then holds the
+// size of the codes in place of the padding and is followed by the smallest
+// level and the step of the grid (float64 each), which makes it 40 bytes
+// long, and every entry is 33 or 34 bytes long.
+func (vp *VPTree) WriteIndex(w io.Writer, recordSize int, encode func(item interface{}, record []byte)) error {
+	return vp.WriteIndexWithLayout(w, recordSize, encode, LayoutPreOrder)
+}
+
+// WriteIndexWithLayout is like WriteIndex, but stores the nodes in the order
+// given by layout. The file format is the same, so OpenMmapIndex can open any
+// layout and finds the same results in each.
+func (vp *VPTree) WriteIndexWithLayout(w io.Writer, recordSize int, encode func(item interface{}, record []byte), layout IndexLayout) error {
+	if recordSize < 1 {
+		return fmt.Errorf("vptree: invalid record size %v", recordSize)
+	}
+
+	var nodes []*node
+	switch layout {
+	case LayoutPreOrder:
+		nodes = vp.indexOrder()
+	case LayoutVanEmdeBoas:
+		nodes = vp.vanEmdeBoasOrder()
+	default:
+		return fmt.Errorf("vptree: invalid index layout %v", layout)
+	}
+	index := make(map[*node]int32, len(nodes))
+	for i, n := range nodes {
+		index[n] = int32(i)
+	}
+	child := func(n *node) int32 {
+		if n == nil {
+			return -1
+		}
+		return index[n]
+	}
+
+	bw := bufio.NewWriter(w)
+
+	codeSize := vp.codeSize()
+	version, headerSize, nodeSize := indexVersion, indexHeaderSize, indexNodeSize
+	if codeSize > 0 {
+		version, headerSize, nodeSize = indexVersionQuantized, indexQuantizedHeaderSize, indexNodeSize-8+codeSize
+	}
+
+	header := make([]byte, headerSize)
+	copy(header, indexMagic[:])
+	binary.LittleEndian.PutUint32(header[4:], uint32(version))
+	binary.LittleEndian.PutUint32(header[8:], uint32(recordSize))
+	binary.LittleEndian.PutUint64(header[16:], uint64(len(nodes)))
+	if codeSize > 0 {
+		binary.LittleEndian.PutUint32(header[12:], uint32(codeSize))
+		binary.LittleEndian.PutUint64(header[24:], math.Float64bits(vp.quantMin))
+		binary.LittleEndian.PutUint64(header[32:], math.Float64bits(vp.quantStep))
+	}
+	bw.Write(header)
+
+	entry := make([]byte, nodeSize)
+	for _, n := range nodes {
+		var bucketStart int32
+		if len(n.Bucket) > 0 {
+			bucketStart = index[n.Bucket[0]]
+		}
+
+		binary.LittleEndian.PutUint64(entry[0:], math.Float64bits(math.Min(vp.leftBound(n), n.LeftRadius)))
+		switch codeSize {
+		case 0:
+			binary.LittleEndian.PutUint64(entry[8:], math.Float64bits(n.Threshold))
+		case 1:
+			entry[8] = uint8(vp.code(n.Threshold))
+		case 2:
+			binary.LittleEndian.PutUint16(entry[8:], uint16(vp.code(n.Threshold)))
+		}
+
+		// The fields after the threshold
+		rest := entry[nodeSize-24:]
+		binary.LittleEndian.PutUint64(rest[0:], math.Float64bits(n.RightRadius))
+		binary.LittleEndian.PutUint32(rest[8:], uint32(child(n.Left)))
+		binary.LittleEndian.PutUint32(rest[12:], uint32(child(n.Right)))
+		binary.LittleEndian.PutUint32(rest[16:], uint32(bucketStart))
+		binary.LittleEndian.PutUint32(rest[20:], uint32(len(n.Bucket)))
+		bw.Write(entry)
+	}
+
+	record := make([]byte, recordSize)
+	for _, n := range nodes {
+		for i := range record {
+			record[i] = 0
+		}
+		encode(n.Item, record)
+		bw.Write(record)
+	}
+
+	return bw.Flush()
+}
+
+// indexOrder returns the nodes of the VP-tree in the order WriteIndex stores
+// them: in pre-order, with the leaves of every bucket next to each other.
+func (vp *VPTree) indexOrder() []*node {
+	var nodes []*node
+
+	var visit func(n *node)
+	visit = func(n *node) {
+		if n == nil {
+			return
+		}
+		nodes = append(nodes, n)
+		nodes = append(nodes, n.Bucket...)
+		visit(n.Left)
+		visit(n.Right)
+	}
+	visit(vp.root)
+
+	return nodes
+}
+
+// vanEmdeBoasOrder returns the nodes of the VP-tree in van Emde Boas order,
+// with the leaves of every bucket next to each other.
+func (vp *VPTree) vanEmdeBoasOrder() []*node {
+	var nodes []*node
+
+	// layout stores the top levels levels of the subtree rooted at n
+	var layout func(n *node, levels int)
+	layout = func(n *node, levels int) {
+		if n == nil {
+			return
+		}
+		if levels == 1 {
+			nodes = append(nodes, n)
+			nodes = append(nodes, n.Bucket...)
+			return
+		}
+
+		top := levels / 2
+		layout(n, top)
+		for _, b := range descendantsAt(n, top) {
+			layout(b, levels-top)
+		}
+	}
+	layout(vp.root, height(vp.root))
+
+	return nodes
+}
+
+// height returns the number of levels of the subtree rooted at n, not counting
+// buckets.
+func height(n *node) int {
+	if n == nil {
+		return 0
+	}
+	return 1 + max(height(n.Left), height(n.Right))
+}
+
+// descendantsAt returns the descendants of n that are depth levels below it,
+// from left to right.
+func descendantsAt(n *node, depth int) []*node {
+	if n == nil {
+		return nil
+	}
+	if depth == 0 {
+		return []*node{n}
+	}
+	return append(descendantsAt(n.Left, depth-1), descendantsAt(n.Right, depth-1)...)
+}
+
+// A MmapVPTree is a VP-tree that is searched directly in a memory-mapped file
+// written by WriteIndex, so opening it is instant and its pages are shared
+// between processes through the page cache.
+type MmapVPTree struct {
+	data       []byte
+	nodes      []byte
+	records    []byte
+	nodeSize   int
+	recordSize int
+	count      int
+	metric     Metric
+
+	// If codeSize is positive, the thresholds are stored as codes of that
+	// many bytes on the grid quantMin + code*quantStep.
+	codeSize  int
+	quantMin  float64
+	quantStep float64
+}
+
+// An indexEntry is a decoded entry of the node table of an index.
+type indexEntry struct {
+	leftMax, rightMin, rightMax float64
+	left, right                 int
+	bucketStart, bucketLen      int
+}
+
+// OpenMmapIndex opens an index written by WriteIndex. Its items are the
+// records of the index, as []byte slices of the mapping, so metric is called
+// with a record as its first argument and the target of the search as its
+// second.
+func OpenMmapIndex(path string, metric Metric) (*MmapVPTree, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < indexHeaderSize {
+		return nil, fmt.Errorf("vptree: %v is too small to be an index", path)
+	}
+
+	data, err := mapFile(f, int(fi.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := parseIndex(data, metric)
+	if err != nil {
+		unmapFile(data)
+		return nil, fmt.Errorf("vptree: %v: %v", path, err)
+	}
+	return t, nil
+}
+
+func parseIndex(data []byte, metric Metric) (*MmapVPTree, error) {
+	if [4]byte(data[:4]) != indexMagic {
+		return nil, errors.New("not an index")
+	}
+
+	t := &MmapVPTree{data: data, nodeSize: indexNodeSize, metric: metric}
+	headerSize := indexHeaderSize
+	switch v := binary.LittleEndian.Uint32(data[4:]); v {
+	case indexVersion:
+	case indexVersionQuantized:
+		if len(data) < indexQuantizedHeaderSize {
+			return nil, errors.New("index is truncated or corrupt")
+		}
+		t.codeSize = int(binary.LittleEndian.Uint32(data[12:]))
+		if t.codeSize != 1 && t.codeSize != 2 {
+			return nil, fmt.Errorf("invalid threshold code size %v", t.codeSize)
+		}
+		headerSize, t.nodeSize = indexQuantizedHeaderSize, indexNodeSize-8+t.codeSize
+		t.quantMin = math.Float64frombits(binary.LittleEndian.Uint64(data[24:]))
+		t.quantStep = math.Float64frombits(binary.LittleEndian.Uint64(data[32:]))
+	default:
+		return nil, fmt.Errorf("unsupported index version %v", v)
+	}
+
+	t.recordSize = int(binary.LittleEndian.Uint32(data[8:]))
+	count := binary.LittleEndian.Uint64(data[16:])
+	if t.recordSize < 1 || count > uint64(len(data))/uint64(t.nodeSize+t.recordSize) ||
+		uint64(len(data)) != uint64(headerSize)+count*uint64(t.nodeSize+t.recordSize) {
+		return nil, errors.New("index is truncated or corrupt")
+	}
+
+	t.count = int(count)
+	nodesEnd := headerSize + t.count*t.nodeSize
+	t.nodes, t.records = data[headerSize:nodesEnd], data[nodesEnd:]
+
+	// Children and bucket nodes must come after their parent, so that a
+	// corrupt index can not make searches loop forever
+	for i := 0; i < t.count; i++ {
+		e := t.entry(i)
+		for _, c := range []int{e.left, e.right} {
+			if c != -1 && (c <= i || c >= t.count) {
+				return nil, fmt.Errorf("node %v has invalid child %v", i, c)
+			}
+		}
+		if e.bucketLen > 0 && (e.bucketStart <= i || e.bucketStart+e.bucketLen > t.count) {
+			return nil, fmt.Errorf("node %v has invalid bucket at %v", i, e.bucketStart)
+		}
+	}
+
+	return t, nil
+}
+
+// entry decodes the entry of node i.
+func (t *MmapVPTree) entry(i int) (e indexEntry) {
+	entry := t.nodes[i*t.nodeSize : (i+1)*t.nodeSize]
+	e.leftMax = math.Float64frombits(binary.LittleEndian.Uint64(entry[0:]))
+	switch t.codeSize {
+	case 0:
+		e.rightMin = math.Float64frombits(binary.LittleEndian.Uint64(entry[8:]))
+	case 1:
+		e.rightMin = t.quantMin + float64(entry[8])*t.quantStep
+	case 2:
+		e.rightMin = t.quantMin + float64(binary.LittleEndian.Uint16(entry[8:]))*t.quantStep
+	}
+
+	// The fields after the threshold
+	rest := entry[t.nodeSize-24:]
+	e.rightMax = math.Float64frombits(binary.LittleEndian.Uint64(rest[0:]))
+	e.left = int(int32(binary.LittleEndian.Uint32(rest[8:])))
+	e.right = int(int32(binary.LittleEndian.Uint32(rest[12:])))
+	e.bucketStart = int(binary.LittleEndian.Uint32(rest[16:]))
+	e.bucketLen = int(binary.LittleEndian.Uint32(rest[20:]))
+	return
+}
+
+// Len returns the number of items in the index.
+func (t *MmapVPTree) Len() int {
+	return t.count
+}
+
+// Close unmaps the index. The records returned by searches must not be used
+// afterwards.
+func (t *MmapVPTree) Close() error {
+	if t.data == nil {
+		return nil
+	}
+	unmapFile(t.data)
+	t.data, t.nodes, t.records = nil, nil, nil
+	return nil
+}
+
+// Search searches the index for the k nearest neighbours of target, like
+// VPTree.Search. The results are the records of the items.
+func (t *MmapVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 || t.count == 0 {
+		return
+	}
+
+	h := make(priorityQueue, 0, k)
+	tau := math.MaxFloat64
+	t.search(0, target, k, &h, &tau)
+
+	results = make([]interface{}, h.Len())
+	distances = make([]float64, h.Len())
+	for i := len(results) - 1; i >= 0; i-- {
+		hi := heap.Pop(&h).(*heapItem)
+		results[i], distances[i] = hi.Item, hi.Dist
+	}
+	return
+}
+
+func (t *MmapVPTree) record(i int) []byte {
+	return t.records[i*t.recordSize : (i+1)*t.recordSize : (i+1)*t.recordSize]
+}
+
+func (t *MmapVPTree) search(i int, target interface{}, k int, h *priorityQueue, tau *float64) {
+	if i < 0 || i >= t.count {
+		return
+	}
+
+	e := t.entry(i)
+
+	item := t.record(i)
+	dist := t.metric(item, target)
+	if dist < *tau || (dist == *tau && h.Len() < k) {
+		if h.Len() == k {
+			heap.Pop(h)
+		}
+		heap.Push(h, &heapItem{Item: item, Dist: dist})
+		if h.Len() == k {
+			*tau = h.Top().(*heapItem).Dist
+		}
+	}
+
+	for b := e.bucketStart; b < e.bucketStart+e.bucketLen; b++ {
+		t.search(b, target, k, h, tau)
+	}
+
+	searchLeft := func() {
+		if dist-*tau <= e.leftMax {
+			t.search(e.left, target, k, h, tau)
+		}
+	}
+	searchRight := func() {
+		if dist+*tau >= e.rightMin && dist-*tau <= e.rightMax {
+			t.search(e.right, target, k, h, tau)
+		}
+	}
+
+	if dist < e.rightMin {
+		searchLeft()
+		searchRight()
+	} else {
+		searchRight()
+		searchLeft()
+	}
+}
@@ -0,0 +1,194 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func encodeCoordinate(item interface{}, record []byte) {
+	c := item.(Coordinate)
+	binary.LittleEndian.PutUint64(record[0:], math.Float64bits(c.X))
+	binary.LittleEndian.PutUint64(record[8:], math.Float64bits(c.Y))
+}
+
+func decodeCoordinate(v interface{}) Coordinate {
+	if record, ok := v.([]byte); ok {
+		return Coordinate{
+			X: math.Float64frombits(binary.LittleEndian.Uint64(record[0:])),
+			Y: math.Float64frombits(binary.LittleEndian.Uint64(record[8:])),
+		}
+	}
+	return v.(Coordinate)
+}
+
+func recordMetric(a, b interface{}) float64 {
+	return CoordinateMetric(decodeCoordinate(a), decodeCoordinate(b))
+}
+
+// writeIndex writes vp to a temporary file and opens it again
+func writeIndex(t testing.TB, write func(f *os.File) error) *MmapVPTree {
+	path := filepath.Join(t.TempDir(), "index")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	idx, err := OpenMmapIndex(path, recordMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { idx.Close() })
+	return idx
+}
+
+// compareIndex makes sure the index finds the same results as vp
+func compareIndex(t *testing.T, vp *VPTree, idx *MmapVPTree) {
+	if idx.Len() != vp.size {
+		t.Fatalf("Expected %v items in the index, got %v", vp.size, idx.Len())
+	}
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		expected, expectedDists := vp.Search(q, 10)
+		results, distances := idx.Search(q, 10)
+
+		decoded := make([]interface{}, len(results))
+		for j, r := range results {
+			decoded[j] = decodeCoordinate(r)
+		}
+		compareResults(t, decoded, distances, expected, expectedDists)
+	}
+}
+
+// This test makes sure a memory-mapped index finds the same results as the
+// tree it was written from
+func TestMmapIndex(t *testing.T) {
+	vp := newRandomCoordinateTree(5000)
+	idx := writeIndex(t, func(f *os.File) error {
+		return vp.WriteIndex(f, 16, encodeCoordinate)
+	})
+	compareIndex(t, vp, idx)
+
+	// Compacted trees have buckets
+	items := make([]interface{}, 200)
+	for i := range items {
+		items[i] = Coordinate{X: float64(i % 3), Y: 0}
+	}
+	dups := New(CoordinateMetric, items)
+	dups.Compact()
+	idx = writeIndex(t, func(f *os.File) error {
+		return dups.WriteIndex(f, 16, encodeCoordinate)
+	})
+	compareIndex(t, dups, idx)
+
+	idx = writeIndex(t, func(f *os.File) error {
+		return dups.WriteIndexWithLayout(f, 16, encodeCoordinate, LayoutVanEmdeBoas)
+	})
+	compareIndex(t, dups, idx)
+
+	path := filepath.Join(t.TempDir(), "garbage")
+	os.WriteFile(path, make([]byte, 100), 0o644)
+	if _, err := OpenMmapIndex(path, recordMetric); err == nil {
+		t.Error("Expected an error for a file that is not an index")
+	}
+}
+
+// This test makes sure indexes whose nodes point back to an ancestor are
+// rejected instead of making searches loop forever
+func TestMmapIndexCorrupt(t *testing.T) {
+	vp := newRandomCoordinateTree(100)
+	var buf bytes.Buffer
+	if err := vp.WriteIndex(&buf, 16, encodeCoordinate); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupt := func(node, offset int, value uint32) {
+		data := append([]byte(nil), buf.Bytes()...)
+		binary.LittleEndian.PutUint32(data[indexHeaderSize+node*indexNodeSize+offset:], value)
+		if offset == 32 {
+			binary.LittleEndian.PutUint32(data[indexHeaderSize+node*indexNodeSize+36:], 1)
+		}
+
+		path := filepath.Join(t.TempDir(), "index")
+		os.WriteFile(path, data, 0o644)
+		if idx, err := OpenMmapIndex(path, recordMetric); err == nil {
+			idx.Close()
+			t.Errorf("expected an error for node %v pointing to node %v", node, value)
+		}
+	}
+	corrupt(0, 24, 0)
+	corrupt(1, 28, 0)
+	corrupt(50, 24, 20)
+	corrupt(0, 32, 0)
+	corrupt(50, 32, 49)
+	corrupt(50, 32, 100)
+}
+
+// This test makes sure the van Emde Boas layout stores every node once, with
+// the root first, and finds the same results as the tree
+func TestIndexLayoutVanEmdeBoas(t *testing.T) {
+	vp := newRandomCoordinateTree(5000)
+
+	nodes := vp.vanEmdeBoasOrder()
+	if len(nodes) != len(vp.indexOrder()) || nodes[0] != vp.root {
+		t.Fatalf("expected %v nodes starting with the root", len(vp.indexOrder()))
+	}
+	seen := make(map[*node]bool)
+	for _, n := range nodes {
+		if seen[n] {
+			t.Fatalf("expected %v to be stored once", n.Item)
+		}
+		seen[n] = true
+	}
+
+	// The top levels come first
+	if nodes[1] != vp.root.Left && nodes[1] != vp.root.Right {
+		t.Errorf("expected a child of the root to follow it, got %v", nodes[1].Item)
+	}
+
+	idx := writeIndex(t, func(f *os.File) error {
+		return vp.WriteIndexWithLayout(f, 16, encodeCoordinate, LayoutVanEmdeBoas)
+	})
+	compareIndex(t, vp, idx)
+
+	if err := vp.WriteIndexWithLayout(io.Discard, 16, encodeCoordinate, IndexLayout(-1)); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+}
+
+func BenchmarkIndexLayout(b *testing.B) {
+	vp := newRandomCoordinateTree(200000)
+	queries := make([]Coordinate, 1000)
+	for i := range queries {
+		queries[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	for _, bm := range []struct {
+		name   string
+		layout IndexLayout
+	}{
+		{"PreOrder", LayoutPreOrder},
+		{"VanEmdeBoas", LayoutVanEmdeBoas},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			idx := writeIndex(b, func(f *os.File) error {
+				return vp.WriteIndexWithLayout(f, 16, encodeCoordinate, bm.layout)
+			})
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				idx.Search(queries[i%len(queries)], 10)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package vptree
+
+import "math"
+
+// Insert adds item to the VP-tree. Inserted items become new leaves, so the
+// tree does not rebalance itself; inserting many items may make searches
+// slower than on a tree that was built from all items at once.
+func (vp *VPTree) Insert(item interface{}) {
+	vp.root = vp.insert(vp.root, item, false)
+	vp.coarse = nil
+	vp.dups = nil
+	vp.epoch++
+	vp.size++
+}
+
+// withInserted returns a copy of the VP-tree with items inserted. The copy
+// shares all nodes with the original except for those on the paths to the new
+// leaves, so the original tree remains unchanged and usable.
+func (vp *VPTree) withInserted(items []interface{}) *VPTree {
+	t := *vp
+	t.coarse = nil
+	t.dups = nil
+	for _, item := range items {
+		t.root = t.insert(t.root, item, true)
+		t.size++
+		t.epoch++
+	}
+	return &t
+}
+
+// insert inserts item into the subtree rooted at n and returns the new root of
+// the subtree. If cow is set, nodes are copied before they are modified.
+func (vp *VPTree) insert(n *node, item interface{}, cow bool) *node {
+	if n == nil {
+		return &node{Item: item, Size: 1}
+	}
+
+	if cow {
+		c := *n
+		n = &c
+	}
+	n.Size++
+
+	dist := vp.distanceMetric(item, n.Item)
+
+	if n.Left == nil && n.Right == nil {
+		n.Threshold = vp.quantize(dist)
+		n.LeftRadius, n.RightRadius = 0, 0
+	}
+
+	if dist < n.Threshold {
+		n.Left = vp.insert(n.Left, item, cow)
+		n.LeftRadius = math.Max(n.LeftRadius, dist)
+	} else {
+		n.Right = vp.insert(n.Right, item, cow)
+		n.RightRadius = math.Max(n.RightRadius, dist)
+	}
+
+	return n
+}
+
+// InsertSortedStream adds a batch of items to the VP-tree that are sorted by
+// some key correlated with their position, e.g. timestamps of a moving sensor
+// or rows from a database index. Inserting such items one by one in order
+// degenerates the tree: every item is a little farther from the existing
+// vantage points than the one before, so it ends up below it, and the tree
+// turns into a chain that is as deep as the batch is long and just as slow to
+// search. InsertSortedStream instead inserts the median of the batch first,
+// then the medians of both halves, and so on, so that every item splits the
+// remaining ones roughly in half, like a balanced binary search tree. If the
+// tree is empty, it is built from the batch directly. The items slice is not
+// modified.
+func (vp *VPTree) InsertSortedStream(items []interface{}) {
+	if vp.root == nil {
+		vp.root = vp.buildFromPoints(append([]interface{}(nil), items...), 0)
+		vp.size = len(items)
+		vp.coarse = nil
+		vp.dups = nil
+		vp.epoch += uint64(len(items))
+		return
+	}
+
+	// Ranges of items that are yet to be inserted, in breadth-first order
+	queue := [][2]int{{0, len(items)}}
+	for len(queue) > 0 {
+		lo, hi := queue[0][0], queue[0][1]
+		queue = queue[1:]
+		if lo >= hi {
+			continue
+		}
+
+		mid := lo + (hi-lo)/2
+		vp.Insert(items[mid])
+		queue = append(queue, [2]int{lo, mid}, [2]int{mid + 1, hi})
+	}
+}
@@ -0,0 +1,100 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test inserts random items into an empty tree and compares searches
+// against the brute-force nearestNeighbours function
+func TestInsert(t *testing.T) {
+	var items []Coordinate
+	vp := New(CoordinateMetric, nil)
+
+	for i := 0; i < 1000; i++ {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		vp.Insert(c)
+	}
+
+	if len(vp.Items()) != len(items) {
+		t.Fatalf("Expected %v items, got %v", len(items), len(vp.Items()))
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		coords1, distances1 := vp.Search(q, 10)
+		coords2, distances2 := nearestNeighbours(q, items, 10)
+		compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+	}
+}
+
+// This test makes sure inserting into a copy leaves the original untouched
+func TestWithInserted(t *testing.T) {
+	var items []Coordinate
+	vpitems := make([]interface{}, 100)
+	for i := range vpitems {
+		c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		items = append(items, c)
+		vpitems[i] = c
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	extra := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	vp2 := vp.withInserted([]interface{}{extra})
+
+	if len(vp.Items()) != len(items) {
+		t.Errorf("Expected the original tree to keep %v items, got %v", len(items), len(vp.Items()))
+	}
+
+	coords1, distances1 := vp2.Search(extra, 5)
+	coords2, distances2 := nearestNeighbours(extra, append(items, extra), 5)
+	compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+}
+
+// This test inserts a sorted batch into a tree and makes sure its depth stays
+// logarithmic, whereas inserting it naively makes it linear
+func TestInsertSortedStream(t *testing.T) {
+	const n = 2000
+
+	sorted := make([]interface{}, n)
+	for i := range sorted {
+		sorted[i] = float64(i)
+	}
+
+	naive := New(absMetric, []interface{}{-1.0, -2.0})
+	for _, item := range sorted {
+		naive.Insert(item)
+	}
+	if depth := naive.Stats().MaxDepth; depth < n/2 {
+		t.Errorf("expected naive inserts to degenerate the tree, got depth %v", depth)
+	}
+
+	for _, initial := range [][]interface{}{nil, {-1.0, -2.0}} {
+		vp := New(absMetric, initial)
+		vp.InsertSortedStream(sorted)
+
+		if err := vp.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		if vp.size != n+len(initial) {
+			t.Errorf("expected %v items, got %v", n+len(initial), vp.size)
+		}
+		// Inserts are not as balanced as a build, but far from linear
+		if depth := vp.Stats().MaxDepth; depth > 10*int(math.Log2(n)) {
+			t.Errorf("expected a logarithmic depth, got %v", depth)
+		}
+		if item := sorted[0]; item != 0.0 {
+			t.Errorf("expected the items not to be modified, got %v first", item)
+		}
+
+		for i := 0; i < 10; i++ {
+			q := rand.Float64() * (n - 1)
+			results, _ := vp.Search(q, 1)
+			if len(results) != 1 || math.Abs(results[0].(float64)-q) > 0.5 {
+				t.Errorf("expected the item nearest to %v, got %v", q, results)
+			}
+		}
+	}
+}
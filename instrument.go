@@ -0,0 +1,90 @@
+package vptree
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// MetricStats collects statistics about the distances returned by a metric
+// wrapped with InstrumentMetric. It is safe for concurrent use.
+type MetricStats struct {
+	calls atomic.Uint64
+	sum   atomicFloat
+	min   atomicFloat
+	max   atomicFloat
+}
+
+// A MetricSnapshot is a copy of the statistics collected by a MetricStats.
+type MetricSnapshot struct {
+	// Calls is the number of times the metric was evaluated.
+	Calls uint64
+
+	// Min, Max and Mean describe the distances the metric returned. They
+	// are 0 if it was never called.
+	Min, Max, Mean float64
+}
+
+// InstrumentMetric wraps m in a metric that records how often it is called
+// and the smallest, largest and mean distance it returns. Pass the wrapped
+// metric to New or any other constructor to monitor the distance computations
+// of the build and of all searches; the wrapper is safe to use from
+// concurrent searches.
+func InstrumentMetric(m Metric) (Metric, *MetricStats) {
+	stats := &MetricStats{}
+	stats.min.store(math.Inf(1))
+	stats.max.store(math.Inf(-1))
+
+	return func(a, b interface{}) float64 {
+		dist := m(a, b)
+		stats.observe(dist)
+		return dist
+	}, stats
+}
+
+func (s *MetricStats) observe(dist float64) {
+	s.sum.update(func(sum float64) float64 { return sum + dist })
+	s.min.update(func(min float64) float64 { return math.Min(min, dist) })
+	s.max.update(func(max float64) float64 { return math.Max(max, dist) })
+	s.calls.Add(1)
+}
+
+// Snapshot returns the statistics collected so far. While the metric is in
+// use, the fields are read one after another, so they may not all reflect
+// exactly the same set of calls.
+func (s *MetricStats) Snapshot() MetricSnapshot {
+	calls := s.calls.Load()
+	if calls == 0 {
+		return MetricSnapshot{}
+	}
+
+	return MetricSnapshot{
+		Calls: calls,
+		Min:   s.min.load(),
+		Max:   s.max.load(),
+		Mean:  s.sum.load() / float64(calls),
+	}
+}
+
+// An atomicFloat is a float64 that can be updated atomically.
+type atomicFloat struct {
+	bits atomic.Uint64
+}
+
+func (f *atomicFloat) load() float64 {
+	return math.Float64frombits(f.bits.Load())
+}
+
+func (f *atomicFloat) store(v float64) {
+	f.bits.Store(math.Float64bits(v))
+}
+
+// update atomically replaces the value v with fn(v).
+func (f *atomicFloat) update(fn func(v float64) float64) {
+	for {
+		old := f.bits.Load()
+		next := math.Float64bits(fn(math.Float64frombits(old)))
+		if next == old || f.bits.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
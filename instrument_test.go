@@ -0,0 +1,62 @@
+package vptree
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// This test feeds a known sequence of distances through an instrumented
+// metric
+func TestInstrumentMetric(t *testing.T) {
+	metric, stats := InstrumentMetric(absMetric)
+
+	if snapshot := stats.Snapshot(); snapshot != (MetricSnapshot{}) {
+		t.Errorf("expected empty statistics, got %+v", snapshot)
+	}
+
+	for _, d := range []float64{3, 1, 4, 1, 5, 9, 2, 6} {
+		if dist := metric(0.0, d); dist != d {
+			t.Errorf("expected distance %v, got %v", d, dist)
+		}
+	}
+
+	expected := MetricSnapshot{Calls: 8, Min: 1, Max: 9, Mean: 31.0 / 8}
+	if snapshot := stats.Snapshot(); snapshot != expected {
+		t.Errorf("expected %+v, got %+v", expected, snapshot)
+	}
+}
+
+// This test makes sure the statistics add up under concurrent searches
+func TestInstrumentMetricConcurrent(t *testing.T) {
+	metric, stats := InstrumentMetric(CoordinateMetric)
+	vp := New(metric, randomCoordinates(1000))
+
+	var mu sync.Mutex
+	calls := stats.Snapshot().Calls
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+			for j := 0; j < 50; j++ {
+				q := Coordinate{X: r.Float64(), Y: r.Float64()}
+				_, _, s := vp.SearchWithStats(q, SearchParameters{K: 5})
+				mu.Lock()
+				calls += uint64(s.MetricCalls)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	snapshot := stats.Snapshot()
+	if snapshot.Calls != calls {
+		t.Errorf("expected %v calls, got %v", calls, snapshot.Calls)
+	}
+	if snapshot.Min < 0 || snapshot.Max > 1.5 || snapshot.Mean < snapshot.Min || snapshot.Mean > snapshot.Max {
+		t.Errorf("implausible statistics %+v", snapshot)
+	}
+}
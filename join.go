@@ -0,0 +1,120 @@
+package vptree
+
+import "sort"
+
+// A Pair is two items of a VP-tree and the distance between them.
+type Pair struct {
+	A, B     interface{}
+	Distance float64
+}
+
+// SelfJoin returns all pairs of distinct items of the VP-tree that are at most
+// radius apart. Every pair is reported once, in no particular order. It runs
+// a range search for every item, so it is much faster than comparing all
+// pairs if radius is small compared to the spread of the items.
+func (vp *VPTree) SelfJoin(radius float64) []Pair {
+	var pairs []Pair
+	vp.selfJoin(radius, func(a, b *node, dist float64) {
+		pairs = append(pairs, Pair{a.Item, b.Item, dist})
+	})
+	return pairs
+}
+
+// RadiusNeighborsGraph returns, for every item of the VP-tree, all other items
+// that are at most radius apart from it, with their distances, in order of
+// least distance to largest distance. Items without such neighbours map to an
+// empty list. This is the adjacency list form of SelfJoin, which is symmetric:
+// b is among the neighbours of a if and only if a is among those of b. Each
+// list corresponds to a row of a sparse distance matrix.
+//
+// The items are used as map keys, so they must be comparable; equal items
+// share one list.
+func (vp *VPTree) RadiusNeighborsGraph(radius float64) map[interface{}][]Neighbor {
+	graph := make(map[interface{}][]Neighbor, vp.size)
+	vp.root.walk(func(n *node) {
+		graph[n.Item] = []Neighbor{}
+	})
+
+	vp.selfJoin(radius, func(a, b *node, dist float64) {
+		graph[a.Item] = append(graph[a.Item], Neighbor{b.Item, dist})
+		graph[b.Item] = append(graph[b.Item], Neighbor{a.Item, dist})
+	})
+
+	for _, neighbours := range graph {
+		sort.SliceStable(neighbours, func(i, j int) bool {
+			return neighbours[i].Distance < neighbours[j].Distance
+		})
+	}
+
+	return graph
+}
+
+// selfJoin calls fn for every pair of distinct nodes whose items are at most
+// radius apart.
+func (vp *VPTree) selfJoin(radius float64, fn func(a, b *node, dist float64)) {
+	nodes := vp.nodes()
+
+	index := make(map[*node]int, len(nodes))
+	for i, n := range nodes {
+		index[n] = i
+	}
+
+	for i, a := range nodes {
+		vp.withinRadius(vp.root, a.Item, radius, func(b *node, dist float64) {
+			if index[b] > i {
+				fn(a, b, dist)
+			}
+		})
+	}
+}
+
+// nodes returns all nodes of the VP-tree in pre-order.
+func (vp *VPTree) nodes() []*node {
+	var nodes []*node
+	vp.root.walk(func(n *node) {
+		nodes = append(nodes, n)
+	})
+	return nodes
+}
+
+// ConnectedComponents groups the items of the VP-tree into connected
+// components, where two items are connected if they are at most radius apart,
+// directly or through a chain of other items. This is single-linkage
+// clustering cut at a fixed distance. Every item is in exactly one component,
+// so isolated items form components of their own. The components are in no
+// particular order.
+func (vp *VPTree) ConnectedComponents(radius float64) [][]interface{} {
+	parent := make(map[*node]*node)
+
+	var find func(n *node) *node
+	find = func(n *node) *node {
+		p, ok := parent[n]
+		if !ok {
+			return n
+		}
+		root := find(p)
+		parent[n] = root
+		return root
+	}
+
+	vp.selfJoin(radius, func(a, b *node, dist float64) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[ra] = rb
+		}
+	})
+
+	var components [][]interface{}
+	index := make(map[*node]int)
+	for _, n := range vp.nodes() {
+		root := find(n)
+		i, ok := index[root]
+		if !ok {
+			i = len(components)
+			index[root] = i
+			components = append(components, nil)
+		}
+		components[i] = append(components[i], n.Item)
+	}
+
+	return components
+}
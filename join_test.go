@@ -0,0 +1,162 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func randomCoordinates(n int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		items[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	return items
+}
+
+// This test compares SelfJoin against checking all pairs
+func TestSelfJoin(t *testing.T) {
+	items := randomCoordinates(300)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for _, radius := range []float64{0, 0.02, 0.1} {
+		expected := 0
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if CoordinateMetric(items[i], items[j]) <= radius {
+					expected++
+				}
+			}
+		}
+
+		pairs := vp.SelfJoin(radius)
+		if len(pairs) != expected {
+			t.Errorf("radius %v: expected %v pairs, got %v", radius, expected, len(pairs))
+		}
+
+		seen := make(map[[2]interface{}]bool)
+		for _, p := range pairs {
+			if p.A == p.B || p.Distance > radius || p.Distance != CoordinateMetric(p.A, p.B) {
+				t.Errorf("radius %v: invalid pair %v", radius, p)
+			}
+			if seen[[2]interface{}{p.A, p.B}] || seen[[2]interface{}{p.B, p.A}] {
+				t.Errorf("radius %v: pair %v reported twice", radius, p)
+			}
+			seen[[2]interface{}{p.A, p.B}] = true
+		}
+	}
+}
+
+// This test compares ConnectedComponents against a brute-force union-find
+func TestConnectedComponents(t *testing.T) {
+	items := randomCoordinates(300)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for _, radius := range []float64{0, 0.03, 0.06, 2} {
+		parent := make([]int, len(items))
+		for i := range parent {
+			parent[i] = i
+		}
+		var find func(i int) int
+		find = func(i int) int {
+			if parent[i] != i {
+				parent[i] = find(parent[i])
+			}
+			return parent[i]
+		}
+		for i := range items {
+			for j := i + 1; j < len(items); j++ {
+				if CoordinateMetric(items[i], items[j]) <= radius {
+					parent[find(i)] = find(j)
+				}
+			}
+		}
+
+		expected := make(map[interface{}]int)
+		roots := make(map[int]bool)
+		for i, item := range items {
+			expected[item] = find(i)
+			roots[find(i)] = true
+		}
+
+		components := vp.ConnectedComponents(radius)
+		if len(components) != len(roots) {
+			t.Fatalf("radius %v: expected %v components, got %v", radius, len(roots), len(components))
+		}
+
+		count := 0
+		for _, c := range components {
+			count += len(c)
+			for _, item := range c {
+				if expected[item] != expected[c[0]] {
+					t.Errorf("radius %v: %v and %v should not be connected", radius, item, c[0])
+				}
+			}
+		}
+		if count != len(items) {
+			t.Errorf("radius %v: expected %v items in components, got %v", radius, len(items), count)
+		}
+
+		switch radius {
+		case 0:
+			if len(components) != len(items) {
+				t.Errorf("expected every item to be isolated, got %v components", len(components))
+			}
+		case 2:
+			if len(components) != 1 {
+				t.Errorf("expected all items to be connected, got %v components", len(components))
+			}
+		}
+	}
+
+	if components := New(CoordinateMetric, nil).ConnectedComponents(1); components != nil {
+		t.Errorf("expected no components in an empty tree, got %v", components)
+	}
+}
+
+// This test compares RadiusNeighborsGraph against brute-force radius searches
+// and makes sure it is symmetric
+func TestRadiusNeighborsGraph(t *testing.T) {
+	items := randomCoordinates(300)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for _, radius := range []float64{0, 0.05, 0.2} {
+		graph := vp.RadiusNeighborsGraph(radius)
+		if len(graph) != len(items) {
+			t.Fatalf("radius %v: expected %v rows, got %v", radius, len(items), len(graph))
+		}
+
+		for _, a := range items {
+			expected := 0
+			for _, b := range items {
+				if a != b && CoordinateMetric(a, b) <= radius {
+					expected++
+				}
+			}
+
+			neighbours, ok := graph[a]
+			if !ok || neighbours == nil {
+				t.Fatalf("radius %v: expected a row for %v", radius, a)
+			}
+			if len(neighbours) != expected {
+				t.Errorf("radius %v: expected %v neighbours of %v, got %v", radius, expected, a, len(neighbours))
+			}
+
+			for i, n := range neighbours {
+				if n.Item == a || n.Distance > radius || n.Distance != CoordinateMetric(a, n.Item) {
+					t.Errorf("radius %v: invalid neighbour %v of %v", radius, n, a)
+				}
+				if i > 0 && n.Distance < neighbours[i-1].Distance {
+					t.Errorf("radius %v: neighbours of %v are not sorted", radius, a)
+				}
+
+				symmetric := false
+				for _, m := range graph[n.Item] {
+					symmetric = symmetric || m.Item == a
+				}
+				if !symmetric {
+					t.Errorf("radius %v: %v is a neighbour of %v, but not vice versa", radius, n.Item, a)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+)
+
+// A Neighbor is an item found by a search, together with its distance to the
+// target of the search.
+type Neighbor struct {
+	Item     interface{}
+	Distance float64
+}
+
+// KNNGraph returns the k nearest neighbours of every item in the VP-tree, not
+// counting the item itself. The neighbours of items[i] are neighbours[i], in
+// order of least distance to largest distance.
+func (vp *VPTree) KNNGraph(k int) (items []interface{}, neighbours [][]Neighbor) {
+	nodes, hits := vp.knnGraph(k)
+
+	items = make([]interface{}, len(nodes))
+	neighbours = make([][]Neighbor, len(nodes))
+	for i, n := range nodes {
+		items[i] = n.Item
+		for _, hi := range hits[i] {
+			neighbours[i] = append(neighbours[i], Neighbor{hi.Item, hi.Dist})
+		}
+	}
+
+	return
+}
+
+// KNNEdges returns the edges of the k-nearest-neighbour graph of the items in
+// the VP-tree, as an edge list for graph libraries: an edge {a, b} means that
+// b is one of the k nearest neighbours of a, not counting a itself. The edges
+// of every item are in order of least distance to largest distance.
+//
+// If mutualOnly is set, it only returns the edges between items that are
+// among each other's k nearest neighbours, and every such pair only once,
+// with the items in the order of the first edge of the pair.
+func (vp *VPTree) KNNEdges(k int, mutualOnly bool) [][2]interface{} {
+	nodes, neighbours := vp.knnGraph(k)
+
+	var edges [][2]interface{}
+	if !mutualOnly {
+		for i, n := range nodes {
+			for _, hi := range neighbours[i] {
+				edges = append(edges, [2]interface{}{n.Item, hi.Item})
+			}
+		}
+		return edges
+	}
+
+	directed := make(map[[2]*node]bool)
+	for i, n := range nodes {
+		for _, hi := range neighbours[i] {
+			directed[[2]*node{n, hi.node}] = true
+		}
+	}
+	for i, n := range nodes {
+		for _, hi := range neighbours[i] {
+			if !directed[[2]*node{hi.node, n}] {
+				continue
+			}
+			// Report the pair at its first edge only
+			delete(directed, [2]*node{n, hi.node})
+			delete(directed, [2]*node{hi.node, n})
+			edges = append(edges, [2]interface{}{n.Item, hi.Item})
+		}
+	}
+
+	return edges
+}
+
+// KthNeighborDistances returns, for every item in the VP-tree, the distance
+// to its k-th nearest neighbour, not counting the item itself. Items with a
+// large k-th neighbour distance lie in sparse regions, which makes this a
+// simple outlier score. If there are fewer than k other items, the distance
+// is +Inf.
+func (vp *VPTree) KthNeighborDistances(k int) (items []interface{}, distances []float64) {
+	items, neighbours := vp.KNNGraph(k)
+
+	distances = make([]float64, len(items))
+	for i, ns := range neighbours {
+		if k < 1 || len(ns) < k {
+			distances[i] = math.Inf(1)
+		} else {
+			distances[i] = ns[k-1].Distance
+		}
+	}
+
+	return
+}
+
+// KDistancePlot returns the distance of every item in the VP-tree to its k-th
+// nearest neighbour, sorted in ascending order. Plotted, these distances
+// usually show a knee, which is a good choice for the eps parameter of
+// DBSCAN with a minimum of k+1 points per cluster. Items with fewer than k
+// other items have a distance of +Inf, which sorts last.
+func (vp *VPTree) KDistancePlot(k int) []float64 {
+	_, distances := vp.KthNeighborDistances(k)
+	sort.Float64s(distances)
+	return distances
+}
+
+// knnGraph returns all nodes in pre-order and the k nearest neighbours of each
+// of them.
+func (vp *VPTree) knnGraph(k int) (nodes []*node, neighbours [][]*heapItem) {
+	vp.root.walk(func(n *node) {
+		nodes = append(nodes, n)
+	})
+
+	neighbours = make([][]*heapItem, len(nodes))
+	if k < 1 {
+		return
+	}
+
+	for i, n := range nodes {
+		s := vp.newSearcher(n.Item, SearchParameters{K: k})
+		s.skip = n
+		s.run()
+		neighbours[i] = s.drain()
+	}
+
+	return
+}
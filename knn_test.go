@@ -0,0 +1,153 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test compares KthNeighborDistances against a brute-force computation
+func TestKthNeighborDistances(t *testing.T) {
+	vpitems := make([]interface{}, 200)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	// A duplicate counts as a neighbour at distance 0
+	vpitems = append(vpitems, vpitems[0])
+
+	vp := New(CoordinateMetric, append([]interface{}(nil), vpitems...))
+
+	for _, k := range []int{1, 3, 10} {
+		items, distances := vp.KthNeighborDistances(k)
+		if len(items) != len(vpitems) {
+			t.Fatalf("Expected %v items, got %v", len(vpitems), len(items))
+		}
+
+		for i, item := range items {
+			var dists []float64
+			skipped := false
+			for _, other := range vpitems {
+				if other == item && !skipped {
+					skipped = true
+					continue
+				}
+				dists = append(dists, CoordinateMetric(item, other))
+			}
+			sort.Float64s(dists)
+
+			if distances[i] != dists[k-1] {
+				t.Errorf("Expected the %v-th neighbour distance of %v to be %v, got %v", k, item, dists[k-1], distances[i])
+			}
+		}
+	}
+}
+
+// This test makes sure KNNGraph excludes the item itself and reports +Inf
+// when there are too few neighbours
+func TestKNNGraph(t *testing.T) {
+	vpitems := []interface{}{0.0, 1.0, 3.0}
+	vp := New(absMetric, vpitems)
+
+	items, neighbours := vp.KNNGraph(5)
+	for i, item := range items {
+		if len(neighbours[i]) != 2 {
+			t.Errorf("Expected 2 neighbours of %v, got %v", item, neighbours[i])
+		}
+		for _, n := range neighbours[i] {
+			if n.Item == item {
+				t.Errorf("Expected %v not to be its own neighbour", item)
+			}
+		}
+	}
+
+	_, distances := vp.KthNeighborDistances(3)
+	for _, d := range distances {
+		if !math.IsInf(d, 1) {
+			t.Errorf("Expected +Inf for a missing neighbour, got %v", d)
+		}
+	}
+}
+
+// This test makes sure KDistancePlot returns the sorted k-th neighbour
+// distances
+func TestKDistancePlot(t *testing.T) {
+	vp := newRandomCoordinateTree(300)
+
+	plot := vp.KDistancePlot(4)
+	_, distances := vp.KthNeighborDistances(4)
+
+	if len(plot) != len(distances) {
+		t.Fatalf("Expected %v distances, got %v", len(distances), len(plot))
+	}
+	if !sort.Float64sAreSorted(plot) {
+		t.Error("Expected the distances to be sorted")
+	}
+
+	sort.Float64s(distances)
+	for i := range plot {
+		if plot[i] != distances[i] {
+			t.Errorf("Expected plot[%v] to be %v, got %v", i, distances[i], plot[i])
+		}
+	}
+
+	small := New(CoordinateMetric, []interface{}{Coordinate{0, 0}, Coordinate{1, 0}})
+	if plot := small.KDistancePlot(2); len(plot) != 2 || !math.IsInf(plot[1], 1) {
+		t.Errorf("Expected +Inf distances with too few items, got %v", plot)
+	}
+}
+
+// This test makes sure KNNEdges lists k edges per item, and that the mutual
+// edges are the pairs of directed edges in both directions, each listed once
+func TestKNNEdges(t *testing.T) {
+	// 0 and 1 are each other's nearest neighbours, 10 is nobody's
+	vp := New(absMetric, []interface{}{0.0, 1.0, 2.5, 10.0})
+
+	directed := vp.KNNEdges(1, false)
+	if len(directed) != 4 {
+		t.Fatalf("Expected 4 directed edges, got %v", directed)
+	}
+	mutual := vp.KNNEdges(1, true)
+	if len(mutual) != 1 || !(mutual[0] == [2]interface{}{0.0, 1.0} || mutual[0] == [2]interface{}{1.0, 0.0}) {
+		t.Errorf("Expected 0 and 1 as the only mutual pair, got %v", mutual)
+	}
+
+	if edges := vp.KNNEdges(2, false); len(edges) != 8 {
+		t.Errorf("Expected 8 directed edges, got %v", edges)
+	}
+	if edges := vp.KNNEdges(3, true); len(edges) != 6 {
+		t.Errorf("Expected all 6 pairs to be mutual, got %v", edges)
+	}
+
+	vp = newRandomCoordinateTree(300)
+	const k = 5
+	directed = vp.KNNEdges(k, false)
+	if len(directed) != 300*k {
+		t.Errorf("Expected %v directed edges, got %v", 300*k, len(directed))
+	}
+
+	isEdge := make(map[[2]interface{}]bool)
+	for _, e := range directed {
+		isEdge[e] = true
+	}
+	seen := make(map[[2]interface{}]bool)
+	for _, e := range vp.KNNEdges(k, true) {
+		if !isEdge[e] || !isEdge[[2]interface{}{e[1], e[0]}] {
+			t.Errorf("Expected %v to be a directed edge in both directions", e)
+		}
+		if seen[e] || seen[[2]interface{}{e[1], e[0]}] {
+			t.Errorf("Expected %v to be listed once", e)
+		}
+		seen[e] = true
+	}
+
+	count := 0
+	for e := range isEdge {
+		if isEdge[[2]interface{}{e[1], e[0]}] {
+			count++
+		}
+	}
+	if len(seen)*2 != count {
+		t.Errorf("Expected %v mutual edges, got %v", count/2, len(seen))
+	}
+}
@@ -0,0 +1,61 @@
+// Package metrics provides ready-made metrics for vptree.
+//
+// Some items are sets of vectors, e.g. documents with several embeddings, and
+// the distance between two items is an aggregation of the distances between
+// their vectors. Only some aggregations are metrics, which the VP-tree
+// requires to find the correct results:
+//
+//   - The Hausdorff distance, the largest distance from a vector of either set
+//     to the closest vector of the other set, is a metric.
+//   - The smallest distance between any two vectors is not: two sets that
+//     share a vector are at distance 0, and it violates the triangle
+//     inequality, since a set can be close to two sets that are far apart.
+//   - The mean distance between all pairs of vectors is not either, because
+//     the distance of a set with more than one distinct vector to itself is
+//     not 0.
+package metrics
+
+import "math"
+
+// Hausdorff returns the Hausdorff distance between two sets of vectors, given
+// as [][]float64, using the Euclidean distance between vectors. It is the
+// largest distance from any vector in either set to the closest vector in the
+// other set. The distance between two empty sets is 0, and between an empty
+// and a non-empty set it is +Inf.
+func Hausdorff(a, b interface{}) float64 {
+	s1, s2 := a.([][]float64), b.([][]float64)
+	if len(s1) == 0 || len(s2) == 0 {
+		if len(s1) == len(s2) {
+			return 0
+		}
+		return math.Inf(1)
+	}
+
+	return math.Max(directedHausdorff(s1, s2), directedHausdorff(s2, s1))
+}
+
+// directedHausdorff returns the largest distance from a vector in s1 to the
+// closest vector in s2.
+func directedHausdorff(s1, s2 [][]float64) float64 {
+	max := 0.0
+	for _, v := range s1 {
+		min := math.Inf(1)
+		for _, w := range s2 {
+			// Once v is at most max away from s2, it cannot raise
+			// the maximum
+			if min = math.Min(min, euclidean(v, w)); min <= max {
+				break
+			}
+		}
+		max = math.Max(max, min)
+	}
+	return max
+}
+
+func euclidean(v, w []float64) float64 {
+	sum := 0.0
+	for i := range v {
+		sum += (v[i] - w[i]) * (v[i] - w[i])
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,76 @@
+package metrics_test
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/DataWraith/vptree"
+	"github.com/DataWraith/vptree/metrics"
+)
+
+// randomSet returns a set of up to five random 3-dimensional vectors
+func randomSet(r *rand.Rand) [][]float64 {
+	set := make([][]float64, r.Intn(5)+1)
+	for i := range set {
+		set[i] = []float64{r.Float64(), r.Float64(), r.Float64()}
+	}
+	return set
+}
+
+// This test makes sure Hausdorff satisfies the properties of a metric on
+// random sets of vectors
+func TestHausdorffIsMetric(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		x, y, z := randomSet(r), randomSet(r), randomSet(r)
+
+		if d := metrics.Hausdorff(x, x); d != 0 {
+			t.Errorf("Expected d(x, x) = 0, got %v", d)
+		}
+		if dxy, dyx := metrics.Hausdorff(x, y), metrics.Hausdorff(y, x); dxy != dyx || dxy < 0 {
+			t.Errorf("Expected d(x, y) = d(y, x) >= 0, got %v and %v", dxy, dyx)
+		}
+		if dxz, dxyz := metrics.Hausdorff(x, z), metrics.Hausdorff(x, y)+metrics.Hausdorff(y, z); dxz > dxyz+1e-12 {
+			t.Errorf("Expected d(x, z) <= d(x, y) + d(y, z), got %v > %v", dxz, dxyz)
+		}
+	}
+
+	if d := metrics.Hausdorff([][]float64{}, [][]float64{}); d != 0 {
+		t.Errorf("Expected 0 between empty sets, got %v", d)
+	}
+	if d := metrics.Hausdorff([][]float64{}, [][]float64{{0}}); !math.IsInf(d, 1) {
+		t.Errorf("Expected +Inf between an empty and a non-empty set, got %v", d)
+	}
+}
+
+// This test makes sure a VP-tree with the Hausdorff metric finds the nearest
+// sets
+func TestHausdorffSearch(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+
+	items := make([]interface{}, 500)
+	for i := range items {
+		items[i] = randomSet(r)
+	}
+	vp := vptree.New(metrics.Hausdorff, append([]interface{}(nil), items...))
+
+	for i := 0; i < 10; i++ {
+		q := randomSet(r)
+
+		expected := make([]float64, len(items))
+		for j, item := range items {
+			expected[j] = metrics.Hausdorff(item, q)
+		}
+		sort.Float64s(expected)
+
+		_, distances := vp.Search(q, 10)
+		for j := range distances {
+			if distances[j] != expected[j] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, expected[j], distances[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package vptree
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// NewFromMmap builds a VP-tree over a binary file of float32 vectors with dim
+// components each, stored back to back in the machine's native byte order.
+// The file is memory-mapped where the platform supports it, so the vectors do
+// not occupy heap memory; elsewhere it is read into memory.
+//
+// The items of the tree are the indices (as int) of the vectors in the file.
+// Targets may be indices as well, or []float32 vectors that need not be in
+// the file. metric measures the distance between two vectors. The mapping is
+// released once the tree, and any tree derived from it, is garbage.
+func NewFromMmap(path string, dim int, metric func(a, b []float32) float64) (*VPTree, error) {
+	if dim < 1 {
+		return nil, fmt.Errorf("vptree: invalid dimension %v", dim)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	stride := int64(dim) * int64(unsafe.Sizeof(float32(0)))
+	if fi.Size()%stride != 0 {
+		return nil, fmt.Errorf("vptree: size %v of %v is not a multiple of %v-dimensional float32 vectors", fi.Size(), path, dim)
+	}
+
+	m := &mmapVectors{dim: dim}
+	if fi.Size() > 0 {
+		if m.data, err = mapFile(f, int(fi.Size())); err != nil {
+			return nil, err
+		}
+		m.floats = unsafe.Slice((*float32)(unsafe.Pointer(&m.data[0])), len(m.data)/4)
+		runtime.SetFinalizer(m, (*mmapVectors).unmap)
+	}
+
+	items := make([]interface{}, len(m.floats)/dim)
+	for i := range items {
+		items[i] = i
+	}
+
+	// The metric closure holds on to m, which keeps the mapping alive for as
+	// long as any tree uses it.
+	return New(func(a, b interface{}) float64 {
+		return metric(m.vector(a), m.vector(b))
+	}, items), nil
+}
+
+// mmapVectors is a set of vectors in a mapped file.
+type mmapVectors struct {
+	dim    int
+	data   []byte
+	floats []float32
+}
+
+// vector returns the vector with index v, or v itself if it is a vector.
+func (m *mmapVectors) vector(v interface{}) []float32 {
+	if i, ok := v.(int); ok {
+		return m.floats[i*m.dim : (i+1)*m.dim : (i+1)*m.dim]
+	}
+	return v.([]float32)
+}
+
+func (m *mmapVectors) unmap() {
+	unmapFile(m.data)
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package vptree
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap support, the file is read into memory instead.
+func mapFile(f *os.File, size int) ([]byte, error) {
+	data := make([]byte, size)
+	_, err := io.ReadFull(f, data)
+	return data, err
+}
+
+func unmapFile(data []byte) {}
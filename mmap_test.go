@@ -0,0 +1,84 @@
+package vptree
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func float32Metric(a, b []float32) float64 {
+	sum := 0.0
+	for i := range a {
+		d := float64(a[i] - b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum)
+}
+
+// This test builds a tree over a file of vectors and compares searches
+// against a brute-force search
+func TestNewFromMmap(t *testing.T) {
+	const n, dim = 2000, 8
+
+	vectors := make([]interface{}, n)
+	data := make([]float32, 0, n*dim)
+	for i := range vectors {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = rand.Float32()
+		}
+		vectors[i] = v
+		data = append(data, v...)
+	}
+
+	path := filepath.Join(t.TempDir(), "vectors.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(f, binary.NativeEndian, data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	vp, err := NewFromMmap(path, dim, float32Metric)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metric := func(a, b interface{}) float64 {
+		return float32Metric(a.([]float32), b.([]float32))
+	}
+
+	for i := 0; i < 10; i++ {
+		// Search by vector and by index
+		for _, q := range []interface{}{vectors[rand.Intn(n)], rand.Intn(n)} {
+			qv := q
+			if idx, ok := q.(int); ok {
+				qv = vectors[idx]
+			}
+
+			results, distances := vp.Search(q, 10)
+			_, expectedDists := BruteForceSearch(metric, vectors, qv, 10)
+
+			for j := range expectedDists {
+				if distances[j] != expectedDists[j] {
+					t.Errorf("Expected distances[%v] to be %v, got %v", j, expectedDists[j], distances[j])
+				}
+				if d := metric(vectors[results[j].(int)], qv); d != distances[j] {
+					t.Errorf("Expected result %v to be %v away, got %v", results[j], distances[j], d)
+				}
+			}
+		}
+	}
+
+	if _, err := NewFromMmap(path, 3, float32Metric); err == nil {
+		t.Error("Expected an error for a file that does not hold whole vectors")
+	}
+	if _, err := NewFromMmap(filepath.Join(t.TempDir(), "missing"), dim, float32Metric); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,52 @@
+package vptree
+
+import "math"
+
+// SearchHasMore is like SearchWithParameters, but also reports whether there
+// are more items within p.MaxDistance beyond the k returned ones, e.g. to
+// decide whether to offer a "more results" button, without returning them.
+//
+// It searches for one more neighbour than requested, so the traversal keeps
+// the nearest item beyond the k-th as a candidate instead of pruning it; that
+// costs only a little more than the search itself. hasMore is true if such an
+// item was found. The extra item is neither returned nor passed to the
+// PayloadResolver or OnResult.
+func (vp *VPTree) SearchHasMore(target interface{}, p SearchParameters) (results []interface{}, distances []float64, hasMore bool) {
+	results, distances, next := vp.searchOneMore(target, p)
+	return results, distances, next != nil
+}
+
+// SearchWithNextDistance is like SearchWithParameters, but also returns the
+// distance of the nearest item that is not among the results, i.e. of the
+// (k+1)-th nearest neighbour, or +Inf if there is none within p.MaxDistance.
+// The gap between a result's distance and next is the margin by which it made
+// it into the results, which shows how clear-cut they are: results with a
+// small margin would change with a slightly different target. Like
+// SearchHasMore, it searches for one more neighbour than requested.
+func (vp *VPTree) SearchWithNextDistance(target interface{}, p SearchParameters) (results []interface{}, distances []float64, next float64) {
+	results, distances, hi := vp.searchOneMore(target, p)
+	if hi == nil {
+		return results, distances, math.Inf(1)
+	}
+	return results, distances, hi.Dist
+}
+
+// searchOneMore searches for the p.K nearest neighbours of target and returns
+// them along with the next nearest one, if any.
+func (vp *VPTree) searchOneMore(target interface{}, p SearchParameters) (results []interface{}, distances []float64, next *heapItem) {
+	if p.K < 1 {
+		return
+	}
+
+	s := vp.newSearcher(target, p)
+	s.k++
+	s.run()
+
+	hits := s.drain()
+	if len(hits) > p.K {
+		hits, next = hits[:p.K], hits[p.K]
+	}
+	results, distances = s.collect(hits)
+
+	return results, distances, next
+}
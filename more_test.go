@@ -0,0 +1,91 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSearchHasMore(t *testing.T) {
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	vp := New(absMetric, items)
+
+	for _, test := range []struct {
+		target      float64
+		k           int
+		maxDistance float64
+		results     int
+		hasMore     bool
+	}{
+		{50, 3, 0, 3, true},
+		{50, 100, 0, 100, false},
+		{50, 200, 0, 100, false},
+		{50, 3, 2.5, 3, true},  // 5 items within the radius
+		{50, 5, 2.5, 5, false}, // exactly k items within the radius
+		{50, 8, 2.5, 5, false}, // fewer than k items within the radius
+		{0, 3, 2, 3, false},    // exactly k items at the edge
+		{0, 2, 2, 2, true},
+	} {
+		results, distances, hasMore := vp.SearchHasMore(test.target, SearchParameters{K: test.k, MaxDistance: test.maxDistance})
+		_, expected := vp.SearchWithParameters(test.target, SearchParameters{K: test.k, MaxDistance: test.maxDistance})
+
+		if len(results) != test.results || hasMore != test.hasMore {
+			t.Errorf("%+v: got %v results, hasMore %v", test, len(results), hasMore)
+			continue
+		}
+		for i := range distances {
+			if distances[i] != expected[i] || absMetric(results[i], test.target) != distances[i] {
+				t.Errorf("%+v: expected distances[%v] to be %v, got %v for %v", test, i, expected[i], distances[i], results[i])
+			}
+		}
+	}
+
+	var reported []int
+	_, _, hasMore := vp.SearchHasMore(50.0, SearchParameters{K: 2, OnResult: func(target, item interface{}, distance float64, rank int) {
+		reported = append(reported, rank)
+	}})
+	if !hasMore || len(reported) != 2 {
+		t.Errorf("expected OnResult to be called for 2 results only, got ranks %v", reported)
+	}
+}
+
+// This test compares the distance of the (k+1)-th neighbour against a
+// brute-force search
+func TestSearchWithNextDistance(t *testing.T) {
+	items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for i := 0; i < 20; i++ {
+		q := randomCoordinates(1)[0]
+		for _, p := range []SearchParameters{{K: 1}, {K: 10}, {K: 10, MaxDistance: 0.05}, {K: 1000}} {
+			_, expected := BruteForceSearch(CoordinateMetric, items, q, p.K+1)
+			if p.MaxDistance > 0 {
+				for j, d := range expected {
+					if d > p.MaxDistance {
+						expected = expected[:j]
+						break
+					}
+				}
+			}
+			next := math.Inf(1)
+			if len(expected) > p.K {
+				expected, next = expected[:p.K], expected[p.K]
+			}
+
+			_, distances, gotNext := vp.SearchWithNextDistance(q, p)
+			if gotNext != next {
+				t.Errorf("%+v: expected next distance %v, got %v", p, next, gotNext)
+			}
+			if len(distances) != len(expected) {
+				t.Fatalf("%+v: expected %v results, got %v", p, len(expected), len(distances))
+			}
+			for j := range distances {
+				if distances[j] != expected[j] {
+					t.Errorf("%+v: expected distances[%v] to be %v, got %v", p, j, expected[j], distances[j])
+				}
+			}
+		}
+	}
+}
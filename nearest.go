@@ -0,0 +1,103 @@
+package vptree
+
+import "math"
+
+// Nearest returns the nearest neighbour of target and its distance, or nil
+// and +Inf if the tree is empty. If several items are equally close, the one
+// that is smallest according to less is returned, so that the result does
+// not depend on how the tree happened to be built. If less is nil, an
+// arbitrary one of them is returned.
+func (vp *VPTree) Nearest(target interface{}, less func(a, b interface{}) bool) (item interface{}, distance float64) {
+	distance = math.Inf(1)
+
+	var ties []interface{}
+	vp.nearestTies(vp.root, target, &distance, &ties)
+
+	for _, t := range ties {
+		if item == nil || (less != nil && less(t, item)) {
+			item = t
+		}
+	}
+
+	return item, distance
+}
+
+// nearestTies collects all items of the subtree rooted at n that are closer
+// to target than *best, or as close, updating *best as it goes.
+func (vp *VPTree) nearestTies(n *node, target interface{}, best *float64, ties *[]interface{}) {
+	if n == nil {
+		return
+	}
+
+	dist := vp.distanceMetric(n.Item, target)
+	if dist < *best {
+		*best = dist
+		*ties = (*ties)[:0]
+	}
+	if dist == *best {
+		*ties = append(*ties, n.Item)
+	}
+
+	for _, b := range n.Bucket {
+		vp.nearestTies(b, target, best, ties)
+	}
+
+	// Unlike Search, only prune subtrees that are strictly farther away
+	// than the best distance, so that no tie is missed.
+	leftBound := math.Min(vp.leftBound(n), n.LeftRadius)
+	searchLeft := func() {
+		if dist-*best <= leftBound {
+			vp.nearestTies(n.Left, target, best, ties)
+		}
+	}
+	searchRight := func() {
+		if dist+*best >= n.Threshold && dist-*best <= n.RightRadius {
+			vp.nearestTies(n.Right, target, best, ties)
+		}
+	}
+
+	if dist < n.Threshold {
+		searchLeft()
+		searchRight()
+	} else {
+		searchRight()
+		searchLeft()
+	}
+}
+
+// NearestAmong returns the nearest neighbour of target whose item is in the
+// allowed set, i.e. maps to true in allowed, and its distance. ok is false if
+// no allowed item is in the tree. The search prunes the tree as usual and
+// merely skips items that are not allowed, so it evaluates the metric far
+// less often than comparing target to all allowed items when the allowed set
+// is large.
+//
+// If equal is nil, items are looked up in allowed directly, so they must be
+// comparable. Otherwise, an item is allowed if equal reports it as equal to
+// one of the allowed keys, which takes time proportional to the size of
+// allowed for every item the search considers.
+func (vp *VPTree) NearestAmong(target interface{}, allowed map[interface{}]bool, equal func(a, b interface{}) bool) (item interface{}, distance float64, ok bool) {
+	isAllowed := func(item interface{}) bool {
+		if equal == nil {
+			return allowed[item]
+		}
+		for a, ok := range allowed {
+			if ok && equal(item, a) {
+				return true
+			}
+		}
+		return false
+	}
+
+	results, distances := vp.SearchWithParameters(target, SearchParameters{
+		K: 1,
+		Exclude: func(item interface{}) bool {
+			return !isAllowed(item)
+		},
+	})
+	if len(results) == 0 {
+		return nil, math.Inf(1), false
+	}
+
+	return results[0], distances[0], true
+}
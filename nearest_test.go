@@ -0,0 +1,103 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure Nearest breaks ties between equidistant items
+// deterministically
+func TestNearestTieBreak(t *testing.T) {
+	// Five items at distance 1 from the origin, and more items farther away
+	var items []interface{}
+	for _, c := range []Coordinate{{1, 0}, {0, 1}, {-1, 0}, {0, -1}, {1, 0}} {
+		items = append(items, c)
+	}
+	for i := 0; i < 500; i++ {
+		angle := rand.Float64() * 2 * math.Pi
+		r := 1.5 + rand.Float64()
+		items = append(items, Coordinate{r * math.Cos(angle), r * math.Sin(angle)})
+	}
+
+	less := func(a, b interface{}) bool {
+		c1, c2 := a.(Coordinate), b.(Coordinate)
+		return c1.X < c2.X || (c1.X == c2.X && c1.Y < c2.Y)
+	}
+
+	for i := 0; i < 20; i++ {
+		rand.Shuffle(len(items), func(i, j int) { items[i], items[j] = items[j], items[i] })
+		vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+		item, dist := vp.Nearest(Coordinate{}, less)
+		if item != (Coordinate{-1, 0}) || dist != 1 {
+			t.Errorf("Expected (%v, 1), got (%v, %v)", Coordinate{-1, 0}, item, dist)
+		}
+
+		if item, dist := vp.Nearest(Coordinate{}, nil); dist != 1 || CoordinateMetric(item, Coordinate{}) != 1 {
+			t.Errorf("Expected any item at distance 1, got (%v, %v)", item, dist)
+		}
+	}
+
+	if item, dist := New(CoordinateMetric, nil).Nearest(Coordinate{}, less); item != nil || !math.IsInf(dist, 1) {
+		t.Errorf("Expected (nil, +Inf) for an empty tree, got (%v, %v)", item, dist)
+	}
+}
+
+// This test makes sure NearestAmong returns the nearest allowed item and
+// skips closer items that are not allowed
+func TestNearestAmong(t *testing.T) {
+	items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	allowed := make(map[interface{}]bool)
+	for i, item := range items {
+		allowed[item] = i%10 == 0
+	}
+	// Allowed keys that are equal to items, but not identical
+	type key struct{ X, Y float64 }
+	keys := make(map[interface{}]bool)
+	for item, ok := range allowed {
+		if ok {
+			c := item.(Coordinate)
+			keys[key{c.X, c.Y}] = true
+		}
+	}
+	equal := func(a, b interface{}) bool {
+		c := a.(Coordinate)
+		return key{c.X, c.Y} == b
+	}
+
+	skipped := 0
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		var expected interface{}
+		best := math.Inf(1)
+		for _, item := range items {
+			if d := CoordinateMetric(item, q); allowed[item] && d < best {
+				expected, best = item, d
+			}
+		}
+
+		item, dist, ok := vp.NearestAmong(q, allowed, nil)
+		if !ok || item != expected || dist != best {
+			t.Errorf("expected %v at %v, got %v at %v (%v)", expected, best, item, dist, ok)
+		}
+		item, dist, ok = vp.NearestAmong(q, keys, equal)
+		if !ok || item != expected || dist != best {
+			t.Errorf("with equal: expected %v at %v, got %v at %v (%v)", expected, best, item, dist, ok)
+		}
+
+		if nearest, _ := vp.Nearest(q, nil); !allowed[nearest] {
+			skipped++
+		}
+	}
+	if skipped == 0 {
+		t.Error("expected the nearest item not to be allowed for some queries")
+	}
+
+	if item, dist, ok := vp.NearestAmong(Coordinate{}, map[interface{}]bool{Coordinate{X: 2}: true}, nil); ok {
+		t.Errorf("expected no allowed item, got %v at %v", item, dist)
+	}
+}
@@ -0,0 +1,41 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+)
+
+// NewWithOutlierRemoval is like New, but first removes outliers from items. An
+// item is an outlier if the distance to its nearest neighbour is larger than
+// the given percentile (between 0 and 100) of all nearest-neighbour
+// distances. A few far-off, bad data points can otherwise distort the choice
+// of vantage points. The removed items are returned alongside the tree.
+//
+// Finding the nearest neighbours requires building a temporary tree, so this
+// takes about twice as long as New.
+func NewWithOutlierRemoval(metric Metric, items []interface{}, percentile float64) (t *VPTree, outliers []interface{}) {
+	all, nnDists := New(metric, append([]interface{}(nil), items...)).KthNeighborDistances(1)
+	if len(all) < 2 || percentile >= 100 {
+		return New(metric, items), nil
+	}
+
+	sorted := append([]float64(nil), nnDists...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(percentile/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	cutoff := sorted[rank]
+
+	var kept []interface{}
+	for i, item := range all {
+		if nnDists[i] > cutoff {
+			outliers = append(outliers, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+
+	return New(metric, kept), outliers
+}
@@ -0,0 +1,53 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure planted outliers are removed from the tree and
+// returned separately
+func TestNewWithOutlierRemoval(t *testing.T) {
+	outliers := []Coordinate{
+		Coordinate{20, 20},
+		Coordinate{-15, 3},
+		Coordinate{4, -30},
+	}
+
+	var vpitems []interface{}
+	for i := 0; i < 500; i++ {
+		vpitems = append(vpitems, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	for _, o := range outliers {
+		vpitems = append(vpitems, o)
+	}
+	total := len(vpitems)
+
+	vp, removed := NewWithOutlierRemoval(CoordinateMetric, vpitems, 99)
+
+	if size := vp.Stats().Size; size+len(removed) != total {
+		t.Fatalf("Expected %v items in total, got %v in the tree and %v removed", total, size, len(removed))
+	}
+
+	for _, o := range outliers {
+		found := false
+		for _, r := range removed {
+			if r == o {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %v to be removed as an outlier", o)
+		}
+
+		if results, distances := vp.Search(o, 1); results[0] == o || distances[0] == 0 {
+			t.Errorf("Expected %v not to be in the tree", o)
+		}
+	}
+
+	// A percentile of 100 keeps everything
+	vpitems = append(vpitems[:0], Coordinate{0, 0}, Coordinate{1, 1}, Coordinate{10, 10})
+	if vp, removed := NewWithOutlierRemoval(CoordinateMetric, vpitems, 100); len(removed) != 0 || vp.Stats().Size != 3 {
+		t.Errorf("Expected no outliers to be removed, got %v", removed)
+	}
+}
@@ -0,0 +1,216 @@
+package vptree
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"math"
+)
+
+// savedTree is the serialized form of a VP-tree. Nodes are stored in
+// pre-order and refer to their children by index, or -1 for none.
+type savedTree struct {
+	Size          int
+	BalanceFactor float64
+	QuantMin      float64
+	QuantStep     float64
+	QuantMax      int
+	Nodes         []savedNode
+}
+
+// If the tree is quantized, savedNode stores the code of its threshold in
+// Code8 or Code16, depending on the number of levels, and leaves Threshold
+// zero, which gob does not encode; see NewQuantized.
+type savedNode struct {
+	Item        interface{}
+	Threshold   float64
+	Code8       uint8
+	Code16      uint16
+	LeftRadius  float64
+	RightRadius float64
+	Size        int
+	Left        int
+	Right       int
+	Bucket      []int
+}
+
+// Save writes the VP-tree to w using encoding/gob, so the types of the items
+// must be registered with gob.Register. Only the structure of the tree is
+// saved, not its metric, vantage point selector, random state or the
+// duplicates collapsed by NewDeduplicated; see RandState for the random
+// state.
+func (vp *VPTree) Save(w io.Writer) error {
+	saved := savedTree{
+		Size:          vp.size,
+		BalanceFactor: vp.balanceFactor,
+		QuantMin:      vp.quantMin,
+		QuantStep:     vp.quantStep,
+		QuantMax:      vp.quantMax,
+	}
+
+	var save func(n *node) int
+	save = func(n *node) int {
+		if n == nil {
+			return -1
+		}
+
+		idx := len(saved.Nodes)
+		sn := savedNode{
+			Item:        n.Item,
+			LeftRadius:  n.LeftRadius,
+			RightRadius: n.RightRadius,
+			Size:        n.Size,
+		}
+		switch vp.codeSize() {
+		case 0:
+			sn.Threshold = n.Threshold
+		case 1:
+			sn.Code8 = uint8(vp.code(n.Threshold))
+		case 2:
+			sn.Code16 = uint16(vp.code(n.Threshold))
+		}
+		saved.Nodes = append(saved.Nodes, sn)
+
+		left := save(n.Left)
+		right := save(n.Right)
+		var bucket []int
+		for _, b := range n.Bucket {
+			bucket = append(bucket, save(b))
+		}
+
+		saved.Nodes[idx].Left, saved.Nodes[idx].Right = left, right
+		saved.Nodes[idx].Bucket = bucket
+		return idx
+	}
+	save(vp.root)
+
+	return gob.NewEncoder(w).Encode(&saved)
+}
+
+// Load reads a VP-tree written by Save from r, using metric as its metric.
+// The thresholds and radii of the tree are loaded as they were saved, so if
+// metric does not measure exactly the same distances as the metric the tree
+// was built with, searches may miss results until Reindex is called.
+func Load(r io.Reader, metric Metric) (*VPTree, error) {
+	var saved savedTree
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, err
+	}
+
+	t := newVPTree(metric, saved.Size)
+	t.balanceFactor = saved.BalanceFactor
+	t.quantMin, t.quantStep, t.quantMax = saved.QuantMin, saved.QuantStep, saved.QuantMax
+
+	nodes := make([]*node, len(saved.Nodes))
+	for i, sn := range saved.Nodes {
+		nodes[i] = &node{
+			Item:        sn.Item,
+			Threshold:   sn.Threshold,
+			LeftRadius:  sn.LeftRadius,
+			RightRadius: sn.RightRadius,
+			Size:        sn.Size,
+		}
+		switch t.codeSize() {
+		case 1:
+			nodes[i].Threshold = t.level(int(sn.Code8))
+		case 2:
+			nodes[i].Threshold = t.level(int(sn.Code16))
+		}
+	}
+
+	// Children and bucket nodes must come after their parent, so that a
+	// corrupt file can not make the tree loop forever
+	for i, sn := range saved.Nodes {
+		valid := func(c int) bool {
+			return c > i && c < len(nodes)
+		}
+
+		for _, c := range []int{sn.Left, sn.Right} {
+			if c != -1 && !valid(c) {
+				return nil, fmt.Errorf("vptree: node %v has invalid child %v", i, c)
+			}
+		}
+		for _, b := range sn.Bucket {
+			if !valid(b) {
+				return nil, fmt.Errorf("vptree: node %v has invalid bucket node %v", i, b)
+			}
+			nodes[i].Bucket = append(nodes[i].Bucket, nodes[b])
+		}
+
+		if sn.Left != -1 {
+			nodes[i].Left = nodes[sn.Left]
+		}
+		if sn.Right != -1 {
+			nodes[i].Right = nodes[sn.Right]
+		}
+	}
+
+	if len(nodes) > 0 {
+		t.root = nodes[0]
+	}
+	return t, nil
+}
+
+// Reindex recomputes the metric-dependent data of the VP-tree from its items,
+// e.g. after loading it with a metric that differs from the one it was saved
+// with. It recomputes the bounding radii and sizes of all nodes, and rebuilds
+// every subtree whose items are no longer on the correct side of its root's
+// threshold. It returns the number of nodes whose data had to be fixed, so a
+// result of 0 confirms that the tree was consistent with its metric.
+func (vp *VPTree) Reindex() (fixed int) {
+	vp.root, fixed = vp.reindex(vp.root, 0)
+	vp.coarse = nil
+	vp.epoch++
+	return
+}
+
+func (vp *VPTree) reindex(n *node, depth int) (*node, int) {
+	if n == nil {
+		return nil, 0
+	}
+
+	fixed := 0
+	size := 1 + len(n.Bucket)
+	valid := true
+
+	leftRadius := 0.0
+	n.Left.walk(func(c *node) {
+		dist := vp.distanceMetric(n.Item, c.Item)
+		leftRadius = math.Max(leftRadius, dist)
+		valid = valid && dist <= vp.leftBound(n)
+		size++
+	})
+
+	rightRadius := 0.0
+	n.Right.walk(func(c *node) {
+		dist := vp.distanceMetric(n.Item, c.Item)
+		rightRadius = math.Max(rightRadius, dist)
+		valid = valid && dist >= n.Threshold
+		size++
+	})
+
+	if !valid {
+		var items []interface{}
+		n.walk(func(c *node) {
+			items = append(items, c.Item)
+		})
+		return vp.buildFromPoints(items, depth), 1
+	}
+
+	if n.LeftRadius != leftRadius || n.RightRadius != rightRadius || n.Size != size {
+		n.LeftRadius, n.RightRadius, n.Size = leftRadius, rightRadius, size
+		fixed++
+	}
+
+	for _, b := range n.Bucket {
+		b.Size = 1
+	}
+
+	var f int
+	n.Left, f = vp.reindex(n.Left, depth+1)
+	fixed += f
+	n.Right, f = vp.reindex(n.Right, depth+1)
+	fixed += f
+
+	return n, fixed
+}
@@ -0,0 +1,113 @@
+package vptree
+
+import (
+	"bytes"
+	"encoding/gob"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func init() {
+	gob.Register(Coordinate{})
+}
+
+func manhattanMetric(a, b interface{}) float64 {
+	c1, c2 := a.(Coordinate), b.(Coordinate)
+	return math.Abs(c1.X-c2.X) + math.Abs(c1.Y-c2.Y)
+}
+
+// This test makes sure a saved and loaded tree searches like the original
+func TestSaveLoad(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+
+	var buf bytes.Buffer
+	if err := vp.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf, CoordinateMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loaded.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if fixed := loaded.Reindex(); fixed != 0 {
+		t.Errorf("Expected Reindex to fix nothing with the same metric, fixed %v nodes", fixed)
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		expected, expectedDists := vp.Search(q, 10)
+		results, distances := loaded.Search(q, 10)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+}
+
+// This test makes sure Reindex restores correct pruning after loading a tree
+// with a different metric
+func TestReindex(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+	items := vp.Items()
+
+	var buf bytes.Buffer
+	if err := vp.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf, manhattanMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loaded.Validate(); err == nil {
+		t.Fatal("Expected the tree to be invalid under a different metric")
+	}
+	if fixed := loaded.Reindex(); fixed == 0 {
+		t.Error("Expected Reindex to fix nodes under a different metric")
+	}
+	if err := loaded.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		expected, expectedDists := BruteForceSearch(manhattanMetric, items, q, 10)
+		results, distances := loaded.Search(q, 10)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+}
+
+// This test makes sure files whose nodes point back to an earlier node are
+// rejected instead of making the tree loop forever
+func TestLoadCorrupt(t *testing.T) {
+	vp := newRandomCoordinateTree(100)
+	vp.Compact()
+
+	for _, corrupt := range []func(nodes []savedNode){
+		func(nodes []savedNode) { nodes[0].Left = 0 },
+		func(nodes []savedNode) { nodes[1].Right = 0 },
+		func(nodes []savedNode) { nodes[50].Left = 20 },
+		func(nodes []savedNode) { nodes[50].Right = 100 },
+		func(nodes []savedNode) { nodes[50].Bucket = []int{49} },
+		func(nodes []savedNode) { nodes[50].Bucket = []int{-1} },
+	} {
+		var buf bytes.Buffer
+		if err := vp.Save(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var saved savedTree
+		if err := gob.NewDecoder(&buf).Decode(&saved); err != nil {
+			t.Fatal(err)
+		}
+		corrupt(saved.Nodes)
+		if err := gob.NewEncoder(&buf).Encode(&saved); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := Load(&buf, CoordinateMetric); err == nil {
+			t.Error("expected an error for a corrupt file")
+		}
+	}
+}
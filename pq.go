@@ -29,3 +29,18 @@ func (pq *priorityQueue) Pop() interface{} {
 func (pq priorityQueue) Top() interface{} {
 	return pq[0]
 }
+
+// A rankedQueue is a priorityQueue that orders items at the same distance by
+// less, so that the item that ranks last is on top.
+type rankedQueue struct {
+	*priorityQueue
+	less func(a, b Neighbor) bool
+}
+
+func (q rankedQueue) Less(i, j int) bool {
+	a, b := (*q.priorityQueue)[i], (*q.priorityQueue)[j]
+	if a.Dist != b.Dist {
+		return a.Dist > b.Dist
+	}
+	return q.less(Neighbor{b.Item, b.Dist}, Neighbor{a.Item, a.Dist})
+}
@@ -0,0 +1,129 @@
+package vptree
+
+import (
+	"math"
+	"sort"
+)
+
+// A QueryProfile summarizes the work the VP-tree did over a set of queries.
+type QueryProfile struct {
+	// Queries is the number of queries that were profiled.
+	Queries int
+
+	// AvgNodesVisited is the average number of nodes visited per query.
+	AvgNodesVisited float64
+
+	// AvgMetricCalls is the average number of metric evaluations per query.
+	AvgMetricCalls float64
+
+	// PrunedFraction is the average fraction of the tree's nodes that a
+	// query did not have to visit. Higher is better.
+	PrunedFraction float64
+}
+
+// ProfileQueries runs a search for each of targets using the parameters p and
+// aggregates the SearchStats of all searches into a QueryProfile. It is meant
+// for evaluating how well the tree prunes on a representative workload.
+func (vp *VPTree) ProfileQueries(targets []interface{}, p SearchParameters) (profile QueryProfile) {
+	if len(targets) == 0 {
+		return
+	}
+
+	var nodes, calls int
+	for _, target := range targets {
+		_, _, stats := vp.SearchWithStats(target, p)
+		nodes += stats.NodesVisited
+		calls += stats.MetricCalls
+	}
+
+	profile.Queries = len(targets)
+	profile.AvgNodesVisited = float64(nodes) / float64(len(targets))
+	profile.AvgMetricCalls = float64(calls) / float64(len(targets))
+	if vp.size > 0 {
+		profile.PrunedFraction = 1 - profile.AvgNodesVisited/float64(vp.size)
+	}
+
+	return
+}
+
+// ErrorStats describes how much farther the nearest neighbours found by
+// approximate searches are than the true nearest neighbours.
+type ErrorStats struct {
+	// Queries is the number of queries that were compared.
+	Queries int
+
+	// Ratios holds, for every query that found a neighbour, the distance
+	// of the approximate nearest neighbour divided by the exact nearest
+	// distance, in increasing order. A ratio of 1 means the search found a
+	// true nearest neighbour. If the exact distance is 0, the ratio is 1 if
+	// the approximate distance is 0, too, and +Inf otherwise.
+	Ratios []float64
+
+	// Mean is the mean of the ratios.
+	Mean float64
+
+	// P99 is the 99th percentile of the ratios.
+	P99 float64
+
+	// Max is the largest ratio.
+	Max float64
+
+	// Missed is the number of queries that found no neighbour although the
+	// exact search did, e.g. because MaxNodes ran out before the search
+	// reached any item that was close enough.
+	Missed int
+}
+
+// ApproxErrorStats compares the nearest neighbour found for each query by a
+// search with the parameters p, e.g. with MaxNodes set, to the exact nearest
+// neighbour found by BruteForceSearch, and returns the distribution of their
+// distance ratios. Unlike the recall, this shows how much worse the
+// approximate answers are, not just how often they are wrong. K is always 1;
+// the ground truth honors MaxDistance and Exclude, but not QuantizeTarget.
+func (vp *VPTree) ApproxErrorStats(queries []interface{}, p SearchParameters) (stats ErrorStats) {
+	p.K = 1
+
+	items := vp.Flatten()
+	if p.Exclude != nil {
+		kept := items[:0]
+		for _, item := range items {
+			if !p.Exclude(item) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+
+	for _, q := range queries {
+		_, exact := BruteForceSearch(vp.distanceMetric, items, q, 1)
+		if len(exact) == 0 || (p.MaxDistance > 0 && exact[0] > p.MaxDistance) {
+			continue
+		}
+
+		stats.Queries++
+		_, approx := vp.SearchWithParameters(q, p)
+		switch {
+		case len(approx) == 0:
+			stats.Missed++
+		case approx[0] == exact[0]:
+			stats.Ratios = append(stats.Ratios, 1)
+		default:
+			stats.Ratios = append(stats.Ratios, approx[0]/exact[0])
+		}
+	}
+
+	if len(stats.Ratios) == 0 {
+		return
+	}
+
+	sort.Float64s(stats.Ratios)
+	sum := 0.0
+	for _, r := range stats.Ratios {
+		sum += r
+	}
+	stats.Mean = sum / float64(len(stats.Ratios))
+	stats.P99 = stats.Ratios[int(math.Ceil(0.99*float64(len(stats.Ratios))))-1]
+	stats.Max = stats.Ratios[len(stats.Ratios)-1]
+
+	return
+}
@@ -0,0 +1,131 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test makes sure that ProfileQueries aggregates the per-query stats
+func TestProfileQueries(t *testing.T) {
+	vpitems := make([]interface{}, 1000)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	targets := make([]interface{}, 50)
+	for i := range targets {
+		targets[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+
+	p := SearchParameters{K: 5}
+
+	var nodes, calls int
+	for _, target := range targets {
+		_, _, stats := vp.SearchWithStats(target, p)
+		nodes += stats.NodesVisited
+		calls += stats.MetricCalls
+	}
+
+	profile := vp.ProfileQueries(targets, p)
+
+	if profile.Queries != len(targets) {
+		t.Errorf("Expected %v queries, got %v", len(targets), profile.Queries)
+	}
+
+	expectedNodes := float64(nodes) / float64(len(targets))
+	if profile.AvgNodesVisited != expectedNodes {
+		t.Errorf("Expected AvgNodesVisited to be %v, got %v", expectedNodes, profile.AvgNodesVisited)
+	}
+
+	expectedCalls := float64(calls) / float64(len(targets))
+	if profile.AvgMetricCalls != expectedCalls {
+		t.Errorf("Expected AvgMetricCalls to be %v, got %v", expectedCalls, profile.AvgMetricCalls)
+	}
+
+	expectedPruned := 1 - expectedNodes/float64(len(vpitems))
+	if math.Abs(profile.PrunedFraction-expectedPruned) > 1e-12 {
+		t.Errorf("Expected PrunedFraction to be %v, got %v", expectedPruned, profile.PrunedFraction)
+	}
+
+	if profile.PrunedFraction <= 0 || profile.PrunedFraction >= 1 {
+		t.Errorf("Expected PrunedFraction to be in (0, 1), got %v", profile.PrunedFraction)
+	}
+}
+
+// This test makes sure an empty workload yields an empty profile
+func TestProfileQueriesEmpty(t *testing.T) {
+	vp := New(CoordinateMetric, nil)
+
+	profile := vp.ProfileQueries(nil, SearchParameters{K: 3})
+	if profile != (QueryProfile{}) {
+		t.Errorf("Expected an empty profile, got %+v", profile)
+	}
+}
+
+// This test makes sure ApproxErrorStats measures the distance ratios on data
+// whose nearest neighbours are known
+func TestApproxErrorStats(t *testing.T) {
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	vp := New(absMetric, items)
+
+	// Every query is 0.25 away from its nearest neighbour
+	queries := make([]interface{}, 200)
+	for i := range queries {
+		queries[i] = float64(i%100) + 0.25
+	}
+
+	stats := vp.ApproxErrorStats(queries, SearchParameters{})
+	if stats.Queries != len(queries) || len(stats.Ratios) != len(queries) || stats.Missed != 0 {
+		t.Fatalf("expected %v ratios, got %+v", len(queries), stats)
+	}
+	if stats.Mean != 1 || stats.P99 != 1 || stats.Max != 1 {
+		t.Errorf("expected exact searches to have ratios of 1, got %+v", stats)
+	}
+
+	// Visiting only the root finds the root
+	stats = vp.ApproxErrorStats(queries, SearchParameters{MaxNodes: 1})
+	root := vp.root.Item.(float64)
+	expected := make([]float64, len(queries))
+	sum := 0.0
+	for i, q := range queries {
+		expected[i] = math.Abs(q.(float64)-root) / 0.25
+		sum += expected[i]
+	}
+	sort.Float64s(expected)
+
+	if len(stats.Ratios) != len(expected) {
+		t.Fatalf("expected %v ratios, got %v", len(expected), len(stats.Ratios))
+	}
+	for i := range expected {
+		if math.Abs(stats.Ratios[i]-expected[i]) > 1e-9 {
+			t.Fatalf("expected ratios[%v] to be %v, got %v", i, expected[i], stats.Ratios[i])
+		}
+	}
+	if math.Abs(stats.Mean-sum/float64(len(expected))) > 1e-9 {
+		t.Errorf("expected a mean of %v, got %v", sum/float64(len(expected)), stats.Mean)
+	}
+	if stats.P99 != expected[197] || stats.Max != expected[199] {
+		t.Errorf("expected a 99th percentile of %v and a maximum of %v, got %v and %v", expected[197], expected[199], stats.P99, stats.Max)
+	}
+
+	// Queries whose nearest neighbour is too far away are not counted
+	far := []interface{}{200.0, 0.25}
+	stats = vp.ApproxErrorStats(far, SearchParameters{MaxDistance: 1})
+	if stats.Queries != 1 || stats.Mean != 1 {
+		t.Errorf("expected 1 exact query, got %+v", stats)
+	}
+
+	// Searches that find nothing within MaxDistance are missed
+	if root > 1.25 {
+		stats = vp.ApproxErrorStats(far, SearchParameters{MaxDistance: 1, MaxNodes: 1})
+		if stats.Queries != 1 || stats.Missed != 1 || len(stats.Ratios) != 0 {
+			t.Errorf("expected 1 missed query, got %+v", stats)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package vptree
+
+import "math"
+
+// NewQuantized is like New, but snaps the thresholds of all nodes to one of
+// 2^bits evenly spaced levels between the smallest and the largest threshold
+// of the tree, so that every threshold is described by a bits-bit code. bits
+// is clamped to the range 1 to 16.
+//
+// Search results remain exact. A threshold T is rounded down to the level L
+// below it, and the next level L+step is above it. Since the items in the
+// right subtree of a node are at least T >= L away from its vantage point,
+// and the items in the left subtree at most T <= L+step, searches use L as
+// the lower bound for the right subtree and L+step as the upper bound for the
+// left subtree. Both bounds are looser than T by at most one step, which only
+// costs a little pruning efficiency. Subtrees that are rebuilt later, e.g. by
+// Remove or Rebuild, are partitioned on the same grid.
+//
+// Save and WriteIndex store every threshold as its code, of one byte for up
+// to 8 bits and two bytes otherwise, together with the smallest level and
+// the step of the grid, instead of as a float64. In memory, nodes keep their
+// decoded thresholds.
+func NewQuantized(metric Metric, items []interface{}, bits int) (t *VPTree) {
+	t = New(metric, items)
+
+	if bits < 1 {
+		bits = 1
+	}
+	if bits > 16 {
+		bits = 16
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	t.root.walk(func(n *node) {
+		if n.Left != nil || n.Right != nil {
+			lo = math.Min(lo, n.Threshold)
+			hi = math.Max(hi, n.Threshold)
+		}
+	})
+	if lo > hi {
+		return
+	}
+
+	t.quantMin = lo
+	t.quantMax = 1<<uint(bits) - 1
+	t.quantStep = (hi - lo) / float64(t.quantMax)
+	if t.quantStep == 0 {
+		// All thresholds are equal and therefore exact; use any step to
+		// mark the tree as quantized.
+		t.quantStep = math.Max(hi, 1) / float64(t.quantMax)
+	}
+
+	t.root.walk(func(n *node) {
+		if n.Left != nil || n.Right != nil {
+			n.Threshold = t.quantize(n.Threshold)
+		}
+	})
+
+	return
+}
+
+// quantize rounds the threshold dist down to the tree's quantization grid, if
+// it has one, making sure that the returned level is at most dist and the next
+// level is at least dist. Distances below the grid are rounded up to its
+// first level, so callers have to place items closer than the returned level
+// in the left subtree.
+func (vp *VPTree) quantize(dist float64) float64 {
+	if vp.quantStep <= 0 {
+		return dist
+	}
+	return vp.level(vp.code(dist))
+}
+
+// code returns the code of the level quantize rounds dist to.
+func (vp *VPTree) code(dist float64) int {
+	code := int(math.Floor((dist - vp.quantMin) / vp.quantStep))
+	if code < 0 {
+		code = 0
+	}
+	if code > vp.quantMax {
+		code = vp.quantMax
+	}
+
+	// Guard against rounding errors in the division above
+	for code > 0 && vp.level(code) > dist {
+		code--
+	}
+	for code < vp.quantMax && vp.level(code+1) <= dist {
+		code++
+	}
+
+	return code
+}
+
+func (vp *VPTree) level(code int) float64 {
+	return vp.quantMin + float64(code)*vp.quantStep
+}
+
+// codeSize returns the number of bytes Save and WriteIndex use to store a
+// threshold code, or 0 if the tree is not quantized.
+func (vp *VPTree) codeSize() int {
+	switch {
+	case vp.quantStep <= 0:
+		return 0
+	case vp.quantMax < 1<<8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// quantizeSplit snaps the threshold of n, whose remaining items have just been
+// partitioned at median, to the grid, and returns the new partition index.
+// Thresholds off the grid, e.g. of items inserted after the grid was chosen,
+// have no level that bounds both subtrees, so the items are partitioned
+// around the level instead.
+func (vp *VPTree) quantizeSplit(n *node, items []interface{}, median int) int {
+	if vp.quantStep <= 0 || len(items) == 0 {
+		return median
+	}
+
+	threshold := n.Threshold
+	n.Threshold = vp.quantize(threshold)
+	if n.Threshold <= threshold && threshold <= vp.leftBound(n) {
+		return median
+	}
+
+	n.LeftRadius, n.RightRadius = 0, 0
+	split := 0
+	for i, item := range items {
+		dist := vp.distanceMetric(item, n.Item)
+		if dist < n.Threshold {
+			items[split], items[i] = items[i], items[split]
+			split++
+			n.LeftRadius = math.Max(n.LeftRadius, dist)
+		} else {
+			n.RightRadius = math.Max(n.RightRadius, dist)
+		}
+	}
+	return split
+}
+
+// leftBound returns an upper bound for the distance between the item of n and
+// the items in its left subtree.
+func (vp *VPTree) leftBound(n *node) float64 {
+	return n.Threshold + vp.quantStep
+}
@@ -0,0 +1,169 @@
+package vptree
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// This test makes sure searches on a tree with quantized thresholds are still
+// exact
+func TestQuantized(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	for _, bits := range []int{1, 4, 8, 16} {
+		vpitems := make([]interface{}, len(items))
+		for i, v := range items {
+			vpitems[i] = interface{}(v)
+		}
+		vp := NewQuantized(CoordinateMetric, vpitems, bits)
+
+		levels := make(map[float64]bool)
+		vp.root.walk(func(n *node) {
+			if n.Left != nil || n.Right != nil {
+				levels[n.Threshold] = true
+			}
+		})
+		if len(levels) > 1<<uint(bits) {
+			t.Errorf("Expected at most %v threshold levels, got %v", 1<<uint(bits), len(levels))
+		}
+
+		// Inserted items must be found as well
+		extra := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		vp.Insert(extra)
+		all := append(append([]Coordinate(nil), items...), extra)
+
+		for i := 0; i < 20; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			k := rand.Intn(20) + 1
+
+			coords1, distances1 := vp.Search(q, k)
+			coords2, distances2 := nearestNeighbours(q, all, k)
+			compareCoordDistSets(t, coords1, coords2, distances1, distances2)
+		}
+	}
+}
+
+// checkGrid makes sure every threshold of vp lies on its quantization grid
+func checkGrid(t *testing.T, vp *VPTree) {
+	t.Helper()
+
+	vp.root.walk(func(n *node) {
+		if (n.Left != nil || n.Right != nil) && vp.level(vp.code(n.Threshold)) != n.Threshold {
+			t.Errorf("expected threshold %v of %v to lie on the grid", n.Threshold, n.Item)
+		}
+	})
+	if err := vp.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+// thresholds returns the thresholds of the nodes of vp that have children, in
+// pre-order
+func thresholds(vp *VPTree) (ts []float64) {
+	vp.root.walk(func(n *node) {
+		if n.Left != nil || n.Right != nil {
+			ts = append(ts, n.Threshold)
+		}
+	})
+	return
+}
+
+// This test makes sure subtrees that are rebuilt after NewQuantized are
+// partitioned on the same grid, also for thresholds beyond it
+func TestQuantizedRebuild(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 500; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	vp := NewQuantized(CoordinateMetric, append([]interface{}(nil), items...), 8)
+	checkGrid(t, vp)
+
+	// Far away items have thresholds beyond the grid
+	var far []interface{}
+	for i := 0; i < 100; i++ {
+		far = append(far, Coordinate{X: 10 + float64(i), Y: 10})
+	}
+	vp.InsertSortedStream(far)
+	checkGrid(t, vp)
+
+	for _, item := range items[:50] {
+		vp.Remove(item)
+	}
+	checkGrid(t, vp)
+
+	vp.Rebuild()
+	checkGrid(t, vp)
+
+	all := append(append([]interface{}(nil), items[50:]...), far...)
+	coords := make([]Coordinate, len(all))
+	for i, item := range all {
+		coords[i] = item.(Coordinate)
+	}
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64() * 20, Y: rand.Float64() * 20}
+		results, distances := vp.Search(q, 10)
+		expected, expectedDists := nearestNeighbours(q, coords, 10)
+		compareCoordDistSets(t, results, expected, distances, expectedDists)
+	}
+}
+
+// This test makes sure Save and WriteIndex store the codes of quantized
+// thresholds rather than their values, and that the trees they write find
+// the same results
+func TestQuantizedEncoding(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	for _, c := range []struct {
+		bits     int
+		nodeSize int
+	}{{8, 33}, {12, 34}} {
+		vp := NewQuantized(CoordinateMetric, append([]interface{}(nil), items...), c.bits)
+
+		// The same tree with its thresholds stored as float64
+		plain := *vp
+		plain.quantStep = 0
+
+		var saved, savedPlain bytes.Buffer
+		if err := vp.Save(&saved); err != nil {
+			t.Fatal(err)
+		}
+		if err := plain.Save(&savedPlain); err != nil {
+			t.Fatal(err)
+		}
+		if saved.Len() >= savedPlain.Len() {
+			t.Errorf("bits %v: expected Save to write less than %v bytes, got %v", c.bits, savedPlain.Len(), saved.Len())
+		}
+
+		loaded, err := Load(&saved, CoordinateMetric)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected, got := thresholds(vp), thresholds(loaded); !reflect.DeepEqual(got, expected) {
+			t.Errorf("bits %v: expected the loaded thresholds to be %v, got %v", c.bits, expected, got)
+		}
+		checkGrid(t, loaded)
+
+		var index bytes.Buffer
+		if err := vp.WriteIndex(&index, 16, encodeCoordinate); err != nil {
+			t.Fatal(err)
+		}
+		if expected := indexQuantizedHeaderSize + len(items)*(c.nodeSize+16); index.Len() != expected {
+			t.Errorf("bits %v: expected an index of %v bytes, got %v", c.bits, expected, index.Len())
+		}
+		idx := writeIndex(t, func(f *os.File) error {
+			_, err := index.WriteTo(f)
+			return err
+		})
+		compareIndex(t, vp, idx)
+		compareIndex(t, loaded, idx)
+	}
+}
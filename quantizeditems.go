@@ -0,0 +1,88 @@
+package vptree
+
+import "sort"
+
+// defaultRerankFactor is the RerankFactor of a new QuantizedItemsVPTree.
+const defaultRerankFactor = 4
+
+// A QuantizedItemsVPTree is a VP-tree that stores its items in a compressed
+// form, e.g. as product-quantized codes, to save memory. Searches find
+// candidates by measuring distances between codes, and then re-rank them by
+// their exact distances to the target, measured on the decoded items.
+type QuantizedItemsVPTree struct {
+	// RerankFactor is the number of candidates per requested neighbour a
+	// search re-ranks. The more candidates, the better the chances that
+	// the true nearest neighbours are among them, despite the errors of
+	// the code metric, but the more items have to be decoded. Values below
+	// 1 mean 1.
+	RerankFactor int
+
+	tree   *VPTree
+	encode func(item interface{}) interface{}
+	decode func(code interface{}) interface{}
+	exact  Metric
+}
+
+// NewQuantizedItems builds a VP-tree over the codes of items, given by encode.
+// codeMetric measures the distance between two codes, and must satisfy the
+// triangle inequality like any metric. Searches return the decoded codes,
+// given by decode, which are usually approximations of the original items.
+// If exactMetric is set, searches re-rank their candidates by the distance
+// exactMetric measures between the target and the decoded codes; otherwise,
+// they return the candidates in the order of their code distances.
+//
+// The items are not kept, so only the codes take up memory.
+func NewQuantizedItems(encode func(item interface{}) interface{}, decode func(code interface{}) interface{}, codeMetric, exactMetric Metric, items []interface{}) *QuantizedItemsVPTree {
+	codes := make([]interface{}, len(items))
+	for i, item := range items {
+		codes[i] = encode(item)
+	}
+
+	return &QuantizedItemsVPTree{
+		RerankFactor: defaultRerankFactor,
+		tree:         New(codeMetric, codes),
+		encode:       encode,
+		decode:       decode,
+		exact:        exactMetric,
+	}
+}
+
+// Len returns the number of items in the tree.
+func (t *QuantizedItemsVPTree) Len() int {
+	return t.tree.size
+}
+
+// Search searches for the k nearest neighbours of target, which is encoded to
+// search the codes. It returns up to k decoded items and their distances in
+// order of least distance to largest distance: their exact distances if the
+// tree has an exact metric, and their code distances otherwise.
+func (t *QuantizedItemsVPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
+	if k < 1 {
+		return
+	}
+
+	code := t.encode(target)
+	if t.exact == nil {
+		codes, distances := t.tree.Search(code, k)
+		for i := range codes {
+			codes[i] = t.decode(codes[i])
+		}
+		return codes, distances
+	}
+
+	codes, _ := t.tree.Search(code, k*max(t.RerankFactor, 1))
+	found := &itemsByDistance{
+		items: make([]interface{}, len(codes)),
+		dists: make([]float64, len(codes)),
+	}
+	for i, c := range codes {
+		found.items[i] = t.decode(c)
+		found.dists[i] = t.exact(found.items[i], target)
+	}
+	sort.Stable(found)
+
+	if found.Len() > k {
+		found.items, found.dists = found.items[:k], found.dists[:k]
+	}
+	return found.items, found.dists
+}
@@ -0,0 +1,96 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// encodeVector quantizes every component of a vector in the unit cube to a
+// byte
+func encodeVector(item interface{}) interface{} {
+	v := item.(Vector)
+	code := make([]byte, len(v))
+	for i, x := range v {
+		code[i] = byte(math.Round(math.Max(0, math.Min(1, x)) * 255))
+	}
+	return code
+}
+
+func decodeVector(code interface{}) interface{} {
+	c := code.([]byte)
+	v := make(Vector, len(c))
+	for i, x := range c {
+		v[i] = float64(x) / 255
+	}
+	return v
+}
+
+func codeMetric(a, b interface{}) float64 {
+	c1, c2 := a.([]byte), b.([]byte)
+
+	sum := 0.0
+	for i := range c1 {
+		d := float64(c1[i]) - float64(c2[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum) / 255
+}
+
+// This test makes sure searches on quantized items find most of the true
+// nearest neighbours, while the codes take an eighth of the memory
+func TestQuantizedItems(t *testing.T) {
+	const dim = 8
+	r := rand.New(rand.NewSource(1))
+	items := uniformVectors(r, 5000, dim)
+
+	for _, exact := range []Metric{nil, VectorMetric} {
+		vp := NewQuantizedItems(encodeVector, decodeVector, codeMetric, exact, items)
+		if vp.Len() != len(items) {
+			t.Fatalf("expected %v items, got %v", len(items), vp.Len())
+		}
+
+		found, total := 0, 0
+		for i := 0; i < 50; i++ {
+			q := uniformVectors(r, 1, dim)[0]
+			expected, _ := BruteForceSearch(VectorMetric, items, q, 10)
+			results, distances := vp.Search(q, 10)
+			if len(results) != 10 {
+				t.Fatalf("expected 10 results, got %v", len(results))
+			}
+
+			for j := range results {
+				if j > 0 && distances[j] < distances[j-1] {
+					t.Errorf("expected the distances to be sorted, got %v", distances)
+				}
+				if exact != nil && distances[j] != VectorMetric(results[j], q) {
+					t.Errorf("expected the exact distance of %v, got %v", results[j], distances[j])
+				}
+			}
+
+			// The results are decoded, so match them up by their codes
+			codes := make(map[string]bool)
+			for _, e := range expected {
+				codes[string(encodeVector(e).([]byte))] = true
+			}
+			for _, res := range results {
+				if codes[string(encodeVector(res).([]byte))] {
+					found++
+				}
+			}
+			total += len(expected)
+		}
+
+		if recall := float64(found) / float64(total); recall < 0.9 {
+			t.Errorf("exact metric %v: expected a recall of at least 0.9, got %v", exact != nil, recall)
+		}
+	}
+
+	vp := NewQuantizedItems(encodeVector, decodeVector, codeMetric, VectorMetric, items)
+	for _, code := range vp.tree.Items() {
+		// A byte instead of a float64 per component
+		if len(code.([]byte)) != dim {
+			t.Fatalf("expected codes of %v bytes, got %v", dim, len(code.([]byte)))
+		}
+	}
+}
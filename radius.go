@@ -0,0 +1,205 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// estimateMaxNodes is the number of nodes EstimateRadiusCount visits
+	// at most.
+	estimateMaxNodes = 128
+
+	// estimateSamples is the number of items EstimateRadiusCount samples
+	// from the subtrees it did not visit.
+	estimateSamples = 128
+)
+
+// SearchRadius returns all items that are at most radius away from target,
+// and the corresponding distances, in order of least distance to largest
+// distance.
+func (vp *VPTree) SearchRadius(target interface{}, radius float64) (results []interface{}, distances []float64) {
+	found := &itemsByDistance{}
+	vp.searchRadius(vp.root, target, radius, found)
+	sort.Sort(found)
+	return found.items, found.dists
+}
+
+func (vp *VPTree) searchRadius(n *node, target interface{}, radius float64, found *itemsByDistance) {
+	vp.withinRadius(n, target, radius, func(n *node, dist float64) {
+		found.items = append(found.items, n.Item)
+		found.dists = append(found.dists, dist)
+	})
+}
+
+// A Constraint names the limit that cut off the results of SearchKWithinR.
+type Constraint int
+
+const (
+	// Neither means that neither limit was reached: all items were returned.
+	Neither Constraint = iota
+
+	// ByK means that k results were returned, so there may be more items
+	// within the radius.
+	ByK
+
+	// ByRadius means that fewer than k results were returned because the
+	// other items are farther away than the radius.
+	ByRadius
+)
+
+// SearchKWithinR returns up to k nearest neighbours of target that are at most
+// radius away from it, like SearchWithParameters with K and MaxDistance, and
+// reports which of the two limits was binding: Neither if it returned all
+// items of the tree, ByK if it returned k results but not all items, and
+// ByRadius if it returned fewer because the remaining items are too far away.
+// A k of less than 1 does not limit the number of results, so the result is
+// only limited by the radius, if at all. radius must be positive.
+func (vp *VPTree) SearchKWithinR(target interface{}, k int, radius float64) (results []interface{}, distances []float64, limitedBy Constraint) {
+	if k < 1 {
+		k = vp.size
+	}
+	results, distances = vp.SearchWithParameters(target, SearchParameters{K: k, MaxDistance: radius})
+
+	switch {
+	case len(results) == vp.size:
+		limitedBy = Neither
+	case len(results) >= k:
+		limitedBy = ByK
+	default:
+		limitedBy = ByRadius
+	}
+
+	return
+}
+
+// withinRadius calls fn for every node of the subtree rooted at n whose item
+// is at most radius away from target.
+func (vp *VPTree) withinRadius(n *node, target interface{}, radius float64, fn func(n *node, dist float64)) {
+	if n == nil {
+		return
+	}
+
+	dist := vp.distanceMetric(n.Item, target)
+	if dist <= radius {
+		fn(n, dist)
+	}
+
+	for _, b := range n.Bucket {
+		vp.withinRadius(b, target, radius, fn)
+	}
+
+	if dist-radius <= math.Min(vp.leftBound(n), n.LeftRadius) {
+		vp.withinRadius(n.Left, target, radius, fn)
+	}
+	if dist+radius >= n.Threshold && dist-radius <= n.RightRadius {
+		vp.withinRadius(n.Right, target, radius, fn)
+	}
+}
+
+// EstimateRadiusCount estimates how many items SearchRadius would return for
+// target and radius, evaluating the metric only a small, fixed number of
+// times. This makes it cheap enough to reject overly broad queries up front.
+//
+// The estimate is exact for small trees. For larger ones, it visits the top
+// levels of the tree, counting subtrees that lie entirely within the radius
+// in full and skipping those that lie entirely outside of it. The subtrees
+// that are left are estimated from random samples of their items, so the
+// estimate is unbiased but noisy, especially if few items are within the
+// radius.
+func (vp *VPTree) EstimateRadiusCount(target interface{}, radius float64) int {
+	count := 0
+	var pending []*node
+
+	// Visit nodes in breadth-first order, so that the budget is spent on
+	// the top levels, where subtrees are largest.
+	queue := []*node{vp.root}
+	for visited := 0; len(queue) > 0; visited++ {
+		n := queue[0]
+		queue = queue[1:]
+		if n == nil {
+			continue
+		}
+		if visited >= estimateMaxNodes {
+			pending = append(pending, n)
+			continue
+		}
+
+		dist := vp.distanceMetric(n.Item, target)
+		if dist <= radius {
+			count++
+		}
+		for _, b := range n.Bucket {
+			if vp.distanceMetric(b.Item, target) <= radius {
+				count++
+			}
+		}
+
+		// Only items whose distance to n.Item lies in [dist-radius,
+		// dist+radius] can be within the radius of the target.
+		for _, child := range []struct {
+			n      *node
+			lo, hi float64
+		}{
+			{n.Left, 0, math.Min(vp.leftBound(n), n.LeftRadius)},
+			{n.Right, n.Threshold, n.RightRadius},
+		} {
+			if child.n == nil || dist-radius > child.hi || dist+radius < child.lo {
+				continue
+			}
+			if dist+child.hi <= radius {
+				count += child.n.Size
+				continue
+			}
+			queue = append(queue, child.n)
+		}
+	}
+
+	if len(pending) == 0 {
+		return count
+	}
+
+	// Spread the samples over the pending subtrees by size
+	total := 0
+	for _, n := range pending {
+		total += n.Size
+	}
+
+	estimate := 0.0
+	for _, n := range pending {
+		samples := (estimateSamples*n.Size + total - 1) / total
+		hits := 0
+		for i := 0; i < samples; i++ {
+			if vp.distanceMetric(randomItem(n, vp.rnd), target) <= radius {
+				hits++
+			}
+		}
+		estimate += float64(n.Size) * float64(hits) / float64(samples)
+	}
+
+	return count + int(math.Round(estimate))
+}
+
+// randomItem returns an item chosen uniformly at random from the subtree
+// rooted at n.
+func randomItem(n *node, rnd *rand.Rand) interface{} {
+	for {
+		i := rnd.Intn(n.Size)
+		if i == 0 {
+			return n.Item
+		}
+		i--
+
+		if i < len(n.Bucket) {
+			return n.Bucket[i].Item
+		}
+		i -= len(n.Bucket)
+
+		if n.Left != nil && i < n.Left.Size {
+			n = n.Left
+		} else {
+			n = n.Right
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// checkRadii makes sure the radii of every node in the tree bound the
+// distances to the items of its subtrees exactly
+func checkRadii(t *testing.T, vp *VPTree) {
+	vp.root.walk(func(n *node) {
+		for _, side := range []struct {
+			child  *node
+			radius float64
+		}{{n.Left, n.LeftRadius}, {n.Right, n.RightRadius}} {
+			max := 0.0
+			side.child.walk(func(c *node) {
+				max = math.Max(max, vp.distanceMetric(n.Item, c.Item))
+			})
+			if side.radius != max {
+				t.Fatalf("Expected a radius of %v, got %v", max, side.radius)
+			}
+		}
+	})
+}
+
+// This test makes sure the bounding radii are exact after building and
+// inserting
+func TestBoundingRadii(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	items := clusteredVectors(r, 1000, 4, 10)
+
+	checkRadii(t, New(VectorMetric, append([]interface{}(nil), items...)))
+	checkRadii(t, NewBalancedFactor(VectorMetric, append([]interface{}(nil), items...), 2))
+
+	vp := New(VectorMetric, append([]interface{}(nil), items[:500]...))
+	for _, item := range items[500:] {
+		vp.Insert(item)
+	}
+	checkRadii(t, vp)
+
+	for i := 0; i < 20; i++ {
+		q := clusteredVectors(r, 1, 4, 1)[0]
+		_, distances := vp.Search(q, 10)
+		_, expectedDists := BruteForceSearch(VectorMetric, items, q, 10)
+
+		for j := range expectedDists {
+			if distances[j] != expectedDists[j] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, expectedDists[j], distances[j])
+			}
+		}
+	}
+}
+
+// This benchmark compares searches with and without pruning by the bounding
+// radii on clustered data
+func BenchmarkBoundingRadii(b *testing.B) {
+	r := rand.New(rand.NewSource(3))
+	items := clusteredVectors(r, 20000, 6, 8)
+	queries := clusteredVectors(r, 100, 6, 8)
+
+	for _, radii := range []bool{true, false} {
+		name := "WithRadii"
+		vp := New(VectorMetric, append([]interface{}(nil), items...))
+		if !radii {
+			name = "WithoutRadii"
+			vp.root.walk(func(n *node) {
+				n.LeftRadius, n.RightRadius = math.Inf(1), math.Inf(1)
+			})
+		}
+
+		b.Run(name, func(b *testing.B) {
+			p := SearchParameters{K: 10}
+
+			nodes := 0
+			for i := 0; i < b.N; i++ {
+				_, _, stats := vp.SearchWithStats(queries[i%len(queries)], p)
+				nodes += stats.NodesVisited
+			}
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}
+
+// This test compares SearchRadius against a brute-force search
+func TestSearchRadius(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		radius := rand.Float64() * 0.2
+
+		count := 0
+		for _, c := range items {
+			if CoordinateMetric(q, c) <= radius {
+				count++
+			}
+		}
+		expected, expectedDists := nearestNeighbours(q, items, count)
+
+		results, distances := vp.SearchRadius(q, radius)
+		compareCoordDistSets(t, results, expected, distances, expectedDists)
+	}
+}
+
+// This test makes sure EstimateRadiusCount is exact for small trees and
+// within a reasonable factor of the true count for large ones
+func TestEstimateRadiusCount(t *testing.T) {
+	small := newRandomCoordinateTree(100)
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		radius := rand.Float64() * 0.5
+
+		results, _ := small.SearchRadius(q, radius)
+		if estimate := small.EstimateRadiusCount(q, radius); estimate != len(results) {
+			t.Errorf("Expected an exact estimate of %v for a small tree, got %v", len(results), estimate)
+		}
+	}
+
+	vp := newRandomCoordinateTree(20000)
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		radius := 0.05 + rand.Float64()*0.2
+
+		results, _ := vp.SearchRadius(q, radius)
+		estimate := vp.EstimateRadiusCount(q, radius)
+		if float64(estimate) < float64(len(results))/2 || float64(estimate) > float64(len(results))*2 {
+			t.Errorf("Expected an estimate close to %v, got %v", len(results), estimate)
+		}
+	}
+}
+
+// This test makes sure SearchKWithinR reports the binding limit
+func TestSearchKWithinR(t *testing.T) {
+	items := make([]interface{}, 10)
+	for i := range items {
+		items[i] = float64(i)
+	}
+	vp := New(absMetric, append([]interface{}(nil), items...))
+
+	for _, test := range []struct {
+		k         int
+		radius    float64
+		count     int
+		limitedBy Constraint
+	}{
+		{3, 5, 3, ByK},
+		{3, 2, 3, ByK},
+		{10, 2, 3, ByRadius},
+		{10, 1.5, 2, ByRadius},
+		{10, 20, 10, Neither},
+		{20, 20, 10, Neither},
+		{20, 9, 10, Neither},
+		{9, 9, 9, ByK},
+		{0, 2, 3, ByRadius},
+		{-1, 20, 10, Neither},
+	} {
+		results, distances, limitedBy := vp.SearchKWithinR(0.0, test.k, test.radius)
+		if len(results) != test.count || len(distances) != test.count {
+			t.Errorf("k %v, radius %v: expected %v results, got %v", test.k, test.radius, test.count, results)
+		}
+		if limitedBy != test.limitedBy {
+			t.Errorf("k %v, radius %v: expected to be limited by %v, got %v", test.k, test.radius, test.limitedBy, limitedBy)
+		}
+		for i, d := range distances {
+			if d != float64(i) {
+				t.Errorf("k %v, radius %v: expected distances[%v] to be %v, got %v", test.k, test.radius, i, i, d)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package vptree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+// A randSource is a rand.Source (the SplitMix64 generator) whose state is a
+// single integer, so that it can be saved and restored; see RandState.
+type randSource struct {
+	state uint64
+}
+
+// newRandSource returns a randSource seeded from the global source.
+func newRandSource() *randSource {
+	return &randSource{state: rand.Uint64()}
+}
+
+func (s *randSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
+
+func (s *randSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+func (s *randSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// RandState returns the state of the random number generator that the
+// VP-tree uses to choose vantage points, e.g. in Rebuild and the sampling
+// selectors, and to draw samples, e.g. in MedianPairwiseDistance,
+// ProblematicItems, EstimateRadiusCount and the cost model of AutoSearch.
+// Restoring it with RestoreRandState, even in another process, makes the
+// following randomized operations choose exactly the same vantage points and
+// samples again, as long as they are applied to the same items in the same
+// order. This helps to reproduce bugs that only show with particular trees.
+// Since these operations advance the shared generator, they must not run
+// concurrently with each other.
+func (vp *VPTree) RandState() []byte {
+	state := make([]byte, 8)
+	binary.BigEndian.PutUint64(state, vp.src.state)
+	return state
+}
+
+// RestoreRandState restores the state of the random number generator saved
+// by RandState.
+func (vp *VPTree) RestoreRandState(state []byte) error {
+	if len(state) != 8 {
+		return fmt.Errorf("vptree: invalid random state of %v bytes", len(state))
+	}
+
+	vp.src.state = binary.BigEndian.Uint64(state)
+	return nil
+}
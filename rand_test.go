@@ -0,0 +1,89 @@
+package vptree
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// This test saves a tree and its random state, and makes sure a rebuild of
+// the reloaded tree with the restored state is identical to the original one
+func TestRandState(t *testing.T) {
+	vp := NewWithSelector(CoordinateMetric, randomCoordinates(2000), SelectorSpread)
+
+	var saved bytes.Buffer
+	if err := vp.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	state := vp.RandState()
+
+	vp.Rebuild()
+	vp.Rebuild()
+
+	loaded, err := Load(bytes.NewReader(saved.Bytes()), CoordinateMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.selector = SelectorSpread
+	if err := loaded.RestoreRandState(state); err != nil {
+		t.Fatal(err)
+	}
+	loaded.Rebuild()
+	loaded.Rebuild()
+
+	if !sameTree(vp.root, loaded.root) {
+		t.Error("Expected the rebuilds to be identical")
+	}
+	if !bytes.Equal(vp.RandState(), loaded.RandState()) {
+		t.Error("Expected the random states to be identical after the rebuilds")
+	}
+
+	// Without restoring the state, the rebuild differs
+	other, err := Load(bytes.NewReader(saved.Bytes()), CoordinateMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Rebuild()
+	if sameTree(vp.root, other.root) {
+		t.Error("Expected a rebuild with another random state to differ")
+	}
+
+	if err := vp.RestoreRandState([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected an invalid state to be rejected")
+	}
+}
+
+// This test makes sure the sampling methods draw from the tree's random
+// number generator, so that restoring its state reproduces their results
+func TestRandStateSampling(t *testing.T) {
+	vp := New(CoordinateMetric, randomCoordinates(5000))
+
+	var saved bytes.Buffer
+	if err := vp.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&saved, CoordinateMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.RestoreRandState(vp.RandState()); err != nil {
+		t.Fatal(err)
+	}
+
+	q := Coordinate{X: 0.5, Y: 0.5}
+	if vp.model().dimension != loaded.model().dimension {
+		t.Errorf("Expected the same dimension, got %v and %v", vp.model().dimension, loaded.model().dimension)
+	}
+	if a, b := vp.MedianPairwiseDistance(51), loaded.MedianPairwiseDistance(51); a != b {
+		t.Errorf("Expected the same median pairwise distance, got %v and %v", a, b)
+	}
+	if a, b := vp.EstimateRadiusCount(q, 0.3), loaded.EstimateRadiusCount(q, 0.3); a != b {
+		t.Errorf("Expected the same radius count estimate, got %v and %v", a, b)
+	}
+	if a, b := vp.ProblematicItems(4), loaded.ProblematicItems(4); !reflect.DeepEqual(a, b) {
+		t.Errorf("Expected the same problematic items, got %v and %v", a, b)
+	}
+	if !bytes.Equal(vp.RandState(), loaded.RandState()) {
+		t.Error("Expected the random states to be identical after sampling")
+	}
+}
@@ -0,0 +1,178 @@
+package vptree
+
+import (
+	"container/heap"
+	"iter"
+	"math"
+)
+
+// A ranking enumerates the items of a VP-tree in order of increasing distance
+// to a target, evaluating the metric only as far as needed. It keeps the
+// frontier of the traversal, the subtrees that have not been visited yet and
+// the items that have been measured but not returned yet, in a queue ordered
+// by distance: a subtree by the lower bound on the distance of its items, an
+// item by its exact distance. Once an item is at the top of the queue, no
+// item anywhere else can be closer, so it is the next one. The traversal can
+// therefore be suspended after any item and resumed later.
+type ranking struct {
+	vp       *VPTree
+	target   interface{}
+	frontier rankQueue
+}
+
+type rankEntry struct {
+	n    *node
+	dist float64
+
+	// measured is set if dist is the distance of n's item, rather than a
+	// lower bound on the distances of n's subtree.
+	measured bool
+}
+
+func (vp *VPTree) newRanking(target interface{}) *ranking {
+	r := &ranking{vp: vp, target: target}
+	if vp.root != nil {
+		r.frontier = rankQueue{{n: vp.root}}
+	}
+	return r
+}
+
+// next returns the next nearest item and its distance, or ok == false if all
+// items have been returned.
+func (r *ranking) next() (item Neighbor, ok bool) {
+	for r.frontier.Len() > 0 {
+		e := heap.Pop(&r.frontier).(rankEntry)
+		if e.measured {
+			return Neighbor{e.n.Item, e.dist}, true
+		}
+
+		n, lower := e.n, e.dist
+		dist := r.vp.distanceMetric(n.Item, r.target)
+		heap.Push(&r.frontier, rankEntry{n, dist, true})
+
+		for _, b := range n.Bucket {
+			heap.Push(&r.frontier, rankEntry{n: b, dist: lower})
+		}
+		if n.Left != nil {
+			leftLower := math.Max(lower, dist-math.Min(r.vp.leftBound(n), n.LeftRadius))
+			heap.Push(&r.frontier, rankEntry{n: n.Left, dist: leftLower})
+		}
+		if n.Right != nil {
+			rightLower := math.Max(lower, math.Max(n.Threshold-dist, dist-n.RightRadius))
+			heap.Push(&r.frontier, rankEntry{n: n.Right, dist: rightLower})
+		}
+	}
+
+	return Neighbor{}, false
+}
+
+// rankQueue is a min-heap of rank entries that returns measured items before
+// subtrees with the same distance.
+type rankQueue []rankEntry
+
+func (q rankQueue) Len() int { return len(q) }
+
+func (q rankQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	return q[i].measured && !q[j].measured
+}
+
+func (q rankQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *rankQueue) Push(e interface{}) { *q = append(*q, e.(rankEntry)) }
+
+func (q *rankQueue) Pop() interface{} {
+	old := *q
+	e := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return e
+}
+
+// SearchUntil retrieves the neighbours of target in batches of batchSize, in
+// order of least distance to largest distance, and passes each batch to fn
+// until fn returns true or the tree is exhausted. This suits re-ranking and
+// filtering without a fixed k: fn can keep asking for more candidates until
+// enough of them pass its quality bar. The search is resumed where it left
+// off for every batch, so the neighbours of earlier batches are not searched
+// again, and only as much of the tree is visited as the batches require.
+func (vp *VPTree) SearchUntil(target interface{}, batchSize int, fn func(candidates []Neighbor) (done bool)) {
+	if batchSize < 1 {
+		return
+	}
+
+	r := vp.newRanking(target)
+	for {
+		batch := make([]Neighbor, 0, batchSize)
+		for len(batch) < batchSize {
+			item, ok := r.next()
+			if !ok {
+				break
+			}
+			batch = append(batch, item)
+		}
+
+		if len(batch) == 0 || fn(batch) || len(batch) < batchSize {
+			return
+		}
+	}
+}
+
+// SearchTopThenRest returns the nearest neighbour of target right away and
+// the remaining neighbours as an iterator that yields them in order of least
+// distance to largest distance, searching the tree only as far as the
+// iteration goes. This suits UIs that show the best result immediately and
+// load more on demand. ok is false if there is no neighbour at all.
+//
+// The search honors MaxDistance, Exclude, IDLookup, PayloadResolver,
+// OnResult and QuantizeTarget of p. If p.K is positive, it returns at most
+// p.K neighbours, including top; otherwise, rest yields all remaining items.
+// rest continues the same search, so it can only be iterated once.
+func (vp *VPTree) SearchTopThenRest(target interface{}, p SearchParameters) (top Neighbor, rest iter.Seq[Neighbor], ok bool) {
+	if p.QuantizeTarget != nil {
+		target = p.QuantizeTarget(target)
+	}
+
+	r := vp.newRanking(target)
+	rank := 0
+	next := func() (Neighbor, bool) {
+		for p.K < 1 || rank < p.K {
+			nb, ok := r.next()
+			if !ok || (p.MaxDistance > 0 && nb.Distance > p.MaxDistance) {
+				break
+			}
+			if p.Exclude != nil && p.Exclude(nb.Item) {
+				continue
+			}
+
+			if p.IDLookup != nil {
+				nb.Item = lookupID(p.IDLookup, nb.Item)
+			}
+			if p.PayloadResolver != nil {
+				nb.Item = p.PayloadResolver(nb.Item)
+			}
+			if p.OnResult != nil {
+				p.OnResult(target, nb.Item, nb.Distance, rank)
+			}
+			rank++
+			return nb, true
+		}
+		return Neighbor{}, false
+	}
+
+	top, ok = next()
+	rest = func(yield func(Neighbor) bool) {
+		if !ok {
+			return
+		}
+		for {
+			nb, more := next()
+			if !more || !yield(nb) {
+				return
+			}
+		}
+	}
+
+	return top, rest, ok
+}
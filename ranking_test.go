@@ -0,0 +1,126 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure SearchUntil delivers the neighbours in batches in
+// distance order and stops when the callback is done
+func TestSearchUntil(t *testing.T) {
+	items := randomCoordinates(1000)
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		_, expected := BruteForceSearch(CoordinateMetric, items, q, len(items))
+
+		// Stop once 5 candidates in the upper half have been seen
+		var received []Neighbor
+		batches, passed := 0, 0
+		vp.SearchUntil(q, 7, func(candidates []Neighbor) bool {
+			batches++
+			if len(candidates) != 7 {
+				t.Errorf("expected batches of 7, got %v", len(candidates))
+			}
+			received = append(received, candidates...)
+			for _, c := range candidates {
+				if c.Item.(Coordinate).Y > 0.5 {
+					passed++
+				}
+			}
+			return passed >= 5
+		})
+
+		if passed < 5 {
+			t.Errorf("expected at least 5 passing candidates, got %v", passed)
+		}
+		if len(received) != 7*batches {
+			t.Errorf("expected %v candidates in %v batches, got %v", 7*batches, batches, len(received))
+		}
+		for j, c := range received {
+			if c.Distance != expected[j] || CoordinateMetric(c.Item, q) != c.Distance {
+				t.Fatalf("expected candidate %v at %v, got %v", j, expected[j], c)
+			}
+		}
+	}
+
+	// Without stopping, all items are delivered
+	total, batches := 0, 0
+	vp.SearchUntil(Coordinate{}, 300, func(candidates []Neighbor) bool {
+		total += len(candidates)
+		batches++
+		return false
+	})
+	if total != len(items) || batches != 4 {
+		t.Errorf("expected %v items in 4 batches, got %v in %v", len(items), total, batches)
+	}
+}
+
+// This test makes sure SearchTopThenRest returns the nearest neighbour first,
+// continues in distance order, and stops searching when the iteration stops
+func TestSearchTopThenRest(t *testing.T) {
+	calls := 0
+	metric := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b)
+	}
+	items := randomCoordinates(2000)
+	vp := New(metric, append([]interface{}(nil), items...))
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		_, expected := BruteForceSearch(CoordinateMetric, items, q, len(items))
+
+		calls = 0
+		top, rest, ok := vp.SearchTopThenRest(q, SearchParameters{})
+		if !ok || top.Distance != expected[0] {
+			t.Fatalf("expected the top result at %v, got %v (%v)", expected[0], top, ok)
+		}
+
+		got := []Neighbor{top}
+		for nb := range rest {
+			got = append(got, nb)
+			if len(got) == 10 {
+				break
+			}
+		}
+		if len(got) != 10 {
+			t.Fatalf("expected 10 neighbours, got %v", len(got))
+		}
+		for j, nb := range got {
+			if nb.Distance != expected[j] || CoordinateMetric(nb.Item, q) != nb.Distance {
+				t.Errorf("expected neighbour %v at %v, got %v", j, expected[j], nb)
+			}
+		}
+		if calls >= len(items)/4 {
+			t.Errorf("expected the search to stop early, but it evaluated the metric %v times", calls)
+		}
+	}
+
+	// K and MaxDistance end the iteration
+	q := Coordinate{X: 0.5, Y: 0.5}
+	for _, p := range []SearchParameters{{K: 5}, {MaxDistance: 0.05}} {
+		results, _ := vp.SearchWithParameters(q, SearchParameters{K: len(items), MaxDistance: p.MaxDistance})
+		if p.K > 0 {
+			results = results[:p.K]
+		}
+
+		top, rest, _ := vp.SearchTopThenRest(q, p)
+		n := 1
+		for range rest {
+			n++
+		}
+		if n != len(results) || top.Item != results[0] {
+			t.Errorf("%+v: expected %v neighbours starting with %v, got %v starting with %v", p, len(results), results[0], n, top.Item)
+		}
+	}
+
+	if _, rest, ok := vp.SearchTopThenRest(q, SearchParameters{MaxDistance: 1e-9}); ok {
+		t.Error("expected no neighbour within the distance")
+	} else {
+		for nb := range rest {
+			t.Errorf("expected no more neighbours, got %v", nb)
+		}
+	}
+}
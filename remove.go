@@ -0,0 +1,113 @@
+package vptree
+
+import "math"
+
+// Remove removes an item that is at distance 0 from item from the VP-tree and
+// reports whether there was one. The subtree below the removed item is
+// rebuilt from its remaining items, so removing items close to the root is
+// expensive.
+func (vp *VPTree) Remove(item interface{}) bool {
+	return vp.removeEqual(item, nil)
+}
+
+// removeEqual is like Remove, but if equal is set, it removes an item that
+// equal reports as equal to item instead. Equal items must be at distance 0.
+func (vp *VPTree) removeEqual(item interface{}, equal func(a, b interface{}) bool) bool {
+	root, removed := vp.remove(vp.root, item, equal, 0)
+	if removed {
+		vp.root = root
+		vp.coarse = nil
+		vp.dups = nil
+		vp.epoch++
+		vp.size--
+	}
+	return removed
+}
+
+// remove removes an item equal to item, or at distance 0 from it if equal is
+// nil, from the subtree rooted at n and returns the new root of the subtree.
+func (vp *VPTree) remove(n *node, item interface{}, equal func(a, b interface{}) bool, depth int) (*node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	matches := func(other interface{}, dist float64) bool {
+		if equal != nil {
+			return equal(item, other)
+		}
+		return dist == 0
+	}
+
+	dist := vp.distanceMetric(item, n.Item)
+	if matches(n.Item, dist) {
+		var rest []interface{}
+		n.walk(func(c *node) {
+			if c != n {
+				rest = append(rest, c.Item)
+			}
+		})
+		return vp.buildFromPoints(rest, depth), true
+	}
+
+	for i, b := range n.Bucket {
+		if matches(b.Item, vp.distanceMetric(item, b.Item)) {
+			n.Bucket = append(n.Bucket[:i:i], n.Bucket[i+1:]...)
+			n.Size--
+			return n, true
+		}
+	}
+
+	// The item can only be in a subtree whose bounds admit its distance.
+	// Removing it only changes the subtree's radius if the item was the
+	// farthest one, in which case the radius is recomputed, so that it
+	// stays exact rather than merely large enough.
+	var removed bool
+	if dist <= math.Min(vp.leftBound(n), n.LeftRadius) {
+		n.Left, removed = vp.remove(n.Left, item, equal, depth+1)
+		if removed && dist >= n.LeftRadius {
+			n.LeftRadius = vp.radius(n.Item, n.Left)
+		}
+	}
+	if !removed && dist >= n.Threshold && dist <= n.RightRadius {
+		n.Right, removed = vp.remove(n.Right, item, equal, depth+1)
+		if removed && dist >= n.RightRadius {
+			n.RightRadius = vp.radius(n.Item, n.Right)
+		}
+	}
+	if removed {
+		n.Size--
+	}
+
+	return n, removed
+}
+
+// radius returns the largest distance from item to any item in the subtree
+// rooted at n, or 0 if the subtree is empty.
+func (vp *VPTree) radius(item interface{}, n *node) (r float64) {
+	n.walk(func(c *node) {
+		r = math.Max(r, vp.distanceMetric(item, c.Item))
+	})
+	return
+}
+
+// PopNearest removes the nearest neighbour of target from the VP-tree and
+// returns it with its distance. ok is false if the tree is empty.
+func (vp *VPTree) PopNearest(target interface{}) (item interface{}, distance float64, ok bool) {
+	results, distances := vp.PopNearestK(target, 1)
+	if len(results) == 0 {
+		return nil, 0, false
+	}
+	return results[0], distances[0], true
+}
+
+// PopNearestK removes the k nearest neighbours of target from the VP-tree and
+// returns them like Search does. Like Remove, it removes items by distance 0,
+// so if the tree holds items at distance 0 from each other, it may remove a
+// different one of them than Search would have returned.
+func (vp *VPTree) PopNearestK(target interface{}, k int) (results []interface{}, distances []float64) {
+	results, distances = vp.Search(target, k)
+	for _, item := range results {
+		vp.Remove(item)
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure the tree stays valid, with exact radii, and searches
+// stay correct after random inserts and removes
+func TestRemove(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+
+	for i := 0; i < 1000; i++ {
+		if rand.Intn(2) == 0 {
+			c := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			items = append(items, c)
+			vp.Insert(c)
+		} else {
+			j := rand.Intn(len(items))
+			if !vp.Remove(items[j]) {
+				t.Fatalf("Expected %v to be removed", items[j])
+			}
+			items[j] = items[len(items)-1]
+			items = items[:len(items)-1]
+		}
+
+		if i%100 == 0 {
+			if err := vp.Validate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if err := vp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if vp.Remove(Coordinate{X: 2, Y: 2}) {
+		t.Error("Expected removing a missing item to fail")
+	}
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		results, distances := vp.Search(q, 10)
+		expected, expectedDists := BruteForceSearch(CoordinateMetric, items, q, 10)
+		compareResults(t, results, distances, expected, expectedDists)
+	}
+
+	for _, item := range items {
+		vp.Remove(item)
+	}
+	if vp.root != nil || vp.size != 0 {
+		t.Errorf("Expected an empty tree after removing all items, got %v items", vp.size)
+	}
+}
+
+// This test makes sure PopNearestK drains the tree in order of distance and
+// leaves it searchable
+func TestPopNearestK(t *testing.T) {
+	var items []interface{}
+	for i := 0; i < 500; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+	q := Coordinate{X: 0.5, Y: 0.5}
+
+	_, expectedDists := BruteForceSearch(CoordinateMetric, items, q, len(items))
+
+	var drained []float64
+	for vp.size > 0 {
+		_, distances := vp.PopNearestK(q, 7)
+		drained = append(drained, distances...)
+
+		if err := vp.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		// The next nearest neighbour must be what is left of the order
+		if _, next := vp.Search(q, 1); len(next) > 0 && next[0] != expectedDists[len(drained)] {
+			t.Fatalf("Expected the nearest remaining item to be %v away, got %v", expectedDists[len(drained)], next[0])
+		}
+	}
+
+	if len(drained) != len(expectedDists) {
+		t.Fatalf("Expected %v items to be drained, got %v", len(expectedDists), len(drained))
+	}
+	for i := range drained {
+		if drained[i] != expectedDists[i] {
+			t.Errorf("Expected drained[%v] to be %v away, got %v", i, expectedDists[i], drained[i])
+		}
+	}
+
+	if _, _, ok := vp.PopNearest(q); ok {
+		t.Error("Expected PopNearest to fail on an empty tree")
+	}
+}
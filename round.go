@@ -0,0 +1,44 @@
+package vptree
+
+import "math"
+
+// RoundMetric wraps m in a metric that rounds every distance to the given
+// number of significant decimal digits, which makes the search results
+// reproducible across architectures. Without rounding, a metric may return
+// slightly different results on different CPUs, e.g. because the compiler
+// fuses multiplications and additions on arm64 but not on amd64; a search
+// then makes different pruning decisions and may order equally good results
+// differently. Rounded distances agree on all platforms unless the exact
+// distance lies right on a rounding boundary, which is vanishingly rare.
+//
+// The rounding works on the binary mantissa, keeping at least as many bits as
+// digits decimal digits need, so it is exact and cheap. It does loosen the
+// guarantees of the tree a little: rounded distances only satisfy the
+// triangle inequality up to a relative error of about 10^-digits, so a search
+// may miss an item whose distance is that close to the distance of the k-th
+// result or to MaxDistance. Distances closer than that are also reported as
+// equal. Digits of 15 or more leave distances unchanged.
+func RoundMetric(m Metric, digits int) Metric {
+	if digits >= 15 {
+		return m
+	}
+
+	bits := int(math.Ceil(float64(digits) * math.Log2(10)))
+	if bits < 1 {
+		bits = 1
+	}
+
+	return func(a, b interface{}) float64 {
+		return roundMantissa(m(a, b), bits)
+	}
+}
+
+// roundMantissa rounds dist to the given number of significant bits.
+func roundMantissa(dist float64, bits int) float64 {
+	if dist == 0 || math.IsInf(dist, 0) || math.IsNaN(dist) {
+		return dist
+	}
+
+	frac, exp := math.Frexp(dist)
+	return math.Ldexp(math.Round(math.Ldexp(frac, bits)), exp-bits)
+}
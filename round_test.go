@@ -0,0 +1,75 @@
+package vptree
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundMetric(t *testing.T) {
+	metric := RoundMetric(absMetric, 3)
+	for _, test := range []struct {
+		a, b, expected float64
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, 0.1, 0.0999755859375},
+		{0, 1234.5678, 1234},
+		{0, math.Inf(1), math.Inf(1)},
+	} {
+		if d := metric(test.a, test.b); d != test.expected {
+			t.Errorf("expected distance between %v and %v to be %v, got %v", test.a, test.b, test.expected, d)
+		}
+	}
+
+	// Distances that agree to 3 digits are rounded alike, and the rounding
+	// error is below 10^-3
+	for _, d := range []float64{0.1, 0.00042, 3.14159, 271828} {
+		rounded := metric(0.0, d)
+		if perturbed := metric(0.0, d*(1+1e-9)); perturbed != rounded {
+			t.Errorf("expected %v and its perturbation to round alike, got %v and %v", d, rounded, perturbed)
+		}
+		if math.Abs(rounded-d) > d*1e-3 {
+			t.Errorf("expected %v to be rounded to 3 digits, got %v", d, rounded)
+		}
+	}
+
+	if d := RoundMetric(absMetric, 15)(0.0, 0.1); d != 0.1 {
+		t.Errorf("expected 15 digits to leave 0.1 unchanged, got %v", d)
+	}
+}
+
+// This test searches a tree with a metric whose results are slightly
+// perturbed, as on a different architecture, and makes sure rounding makes
+// the searches take exactly the same pruning decisions
+func TestRoundMetricReproducible(t *testing.T) {
+	calls := 0
+	perturbed := func(a, b interface{}) float64 {
+		calls++
+		return CoordinateMetric(a, b) * (1 + float64(calls%7-3)*1e-15)
+	}
+
+	items := randomCoordinates(2000)
+	vp := New(RoundMetric(CoordinateMetric, 6), items)
+	other := *vp
+	other.distanceMetric = RoundMetric(perturbed, 6)
+
+	for i := 0; i < 50; i++ {
+		q := randomCoordinates(1)[0]
+		p := SearchParameters{K: 10}
+
+		results, distances, stats := vp.SearchWithStats(q, p)
+		otherResults, otherDistances, otherStats := other.SearchWithStats(q, p)
+
+		if stats != otherStats {
+			t.Errorf("expected identical pruning, got %+v and %+v", stats, otherStats)
+		}
+		if len(results) != len(otherResults) {
+			t.Fatalf("expected %v results, got %v", len(results), len(otherResults))
+		}
+		for j := range results {
+			if results[j] != otherResults[j] || distances[j] != otherDistances[j] {
+				t.Errorf("expected result %v to be %v at %v, got %v at %v", j, results[j], distances[j], otherResults[j], otherDistances[j])
+			}
+		}
+	}
+}
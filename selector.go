@@ -0,0 +1,202 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// selectorCandidates is the number of candidate vantage points the
+	// sampling selectors consider.
+	selectorCandidates = 8
+
+	// selectorSample is the number of items the sampling selectors measure
+	// the candidates against.
+	selectorSample = 16
+)
+
+// A VantageSelector chooses the vantage point of a node from the items of its
+// subtree and returns the index of the chosen item. rnd is the source of
+// randomness the selector should use.
+type VantageSelector func(items []interface{}, metric Metric, rnd *rand.Rand) int
+
+// SelectorRandom chooses a vantage point uniformly at random. This is what New
+// does; it costs no metric evaluations.
+func SelectorRandom(items []interface{}, metric Metric, rnd *rand.Rand) int {
+	return rnd.Intn(len(items))
+}
+
+// SelectorSpread chooses, out of a few random candidates, the one whose
+// distances to a random sample of items have the largest variance. Such
+// vantage points tend to lie at the edge of the data and split it into shells
+// that are easy to tell apart. This is the classic heuristic by Yianilos.
+//
+// In BenchmarkSelectorsUniform (uniform data in 6 dimensions, averaged over
+// trees built from 4 fixed seeds), SelectorSpread visits about 9% fewer nodes
+// than SelectorRandom. In BenchmarkSelectorsClustered (8 tight clusters in 6
+// dimensions), it visits about 2% fewer.
+func SelectorSpread(items []interface{}, metric Metric, rnd *rand.Rand) int {
+	return selectBySample(items, metric, rnd, func(mean, variance float64) float64 {
+		return variance
+	})
+}
+
+// SelectorCenter chooses, out of a few random candidates, the one with the
+// smallest average distance to a random sample of items, i.e. a candidate
+// close to the center of the data or of a dense cluster.
+//
+// Central vantage points can separate a cluster from its surroundings, but
+// in our benchmarks this did not pay off: SelectorCenter visits about 11% more
+// nodes than SelectorRandom both in BenchmarkSelectorsUniform and in
+// BenchmarkSelectorsClustered. Measure on your own data before choosing it
+// over SelectorSpread.
+func SelectorCenter(items []interface{}, metric Metric, rnd *rand.Rand) int {
+	return selectBySample(items, metric, rnd, func(mean, variance float64) float64 {
+		return -mean
+	})
+}
+
+// SelectorMinSearchCost chooses, out of a few random candidates, the one that
+// minimizes the estimated cost of searching its node. It simulates queries
+// for a random sample of items: a query has to search both subtrees of a
+// vantage point if its distance to it is within the query's search radius of
+// the median distance, and only one of them otherwise. The search radius of
+// a query is estimated as its distance to the nearest other item of the
+// sample. The candidate that sends the fewest queries down both subtrees wins,
+// with ties broken by the largest variance, as in SelectorSpread.
+//
+// This is the most expensive selector: on top of what SelectorSpread does, it
+// measures all pairs of sampled items at every node, which roughly doubles
+// the metric evaluations of a build. In BenchmarkSelectorsUniform, it visits
+// about 8% fewer nodes than SelectorRandom, slightly more than
+// SelectorSpread; in BenchmarkSelectorsClustered, it visits about 1% fewer.
+func SelectorMinSearchCost(items []interface{}, metric Metric, rnd *rand.Rand) int {
+	if len(items) <= 2 {
+		return rnd.Intn(len(items))
+	}
+
+	sample := sampleItems(items, selectorSample, rnd)
+
+	// The search radius of every sampled query
+	radius := make([]float64, len(sample))
+	for i := range radius {
+		radius[i] = math.Inf(1)
+	}
+	for i := range sample {
+		for j := i + 1; j < len(sample); j++ {
+			if d := metric(sample[i], sample[j]); d > 0 {
+				radius[i] = math.Min(radius[i], d)
+				radius[j] = math.Min(radius[j], d)
+			}
+		}
+	}
+
+	best, bestCost, bestVariance := 0, math.MaxInt, math.Inf(-1)
+	dists := make([]float64, len(sample))
+	for c := 0; c < selectorCandidates && c < len(items); c++ {
+		idx := rnd.Intn(len(items))
+		for i, s := range sample {
+			dists[i] = metric(items[idx], s)
+		}
+		_, variance := moments(dists)
+
+		sorted := append([]float64(nil), dists...)
+		sort.Float64s(sorted)
+		median := sorted[len(sorted)/2]
+
+		cost := 0
+		for i, d := range dists {
+			if math.Abs(d-median) <= radius[i] {
+				cost++
+			}
+		}
+
+		if cost < bestCost || (cost == bestCost && variance > bestVariance) {
+			best, bestCost, bestVariance = idx, cost, variance
+		}
+	}
+
+	return best
+}
+
+// selectBySample picks the candidate that maximizes score, given the mean
+// and variance of the candidate's distances to a random sample of items.
+func selectBySample(items []interface{}, metric Metric, rnd *rand.Rand, score func(mean, variance float64) float64) int {
+	if len(items) <= 2 {
+		return rnd.Intn(len(items))
+	}
+
+	sample := sampleItems(items, selectorSample, rnd)
+
+	best, bestScore := 0, math.Inf(-1)
+	for c := 0; c < selectorCandidates && c < len(items); c++ {
+		idx := rnd.Intn(len(items))
+		mean, variance := distanceMoments(items[idx], sample, metric)
+		if sc := score(mean, variance); sc > bestScore {
+			best, bestScore = idx, sc
+		}
+	}
+
+	return best
+}
+
+// sampleItems returns up to n items chosen at random, with replacement.
+func sampleItems(items []interface{}, n int, rnd *rand.Rand) []interface{} {
+	if n > len(items) {
+		n = len(items)
+	}
+
+	sample := make([]interface{}, n)
+	for i := range sample {
+		sample[i] = items[rnd.Intn(len(items))]
+	}
+
+	return sample
+}
+
+// distanceMoments returns the mean and variance of the distances between item
+// and the items of sample.
+func distanceMoments(item interface{}, sample []interface{}, metric Metric) (mean, variance float64) {
+	if len(sample) == 0 {
+		return
+	}
+
+	dists := make([]float64, len(sample))
+	for i, s := range sample {
+		dists[i] = metric(item, s)
+	}
+
+	return moments(dists)
+}
+
+// moments returns the mean and variance of dists, which must not be empty.
+func moments(dists []float64) (mean, variance float64) {
+	var sum, sumSq float64
+	for _, d := range dists {
+		sum += d
+		sumSq += d * d
+	}
+
+	mean = sum / float64(len(dists))
+	variance = math.Max(sumSq/float64(len(dists))-mean*mean, 0)
+
+	return
+}
+
+// NewWithSelector is like New, but uses selector to choose the vantage points.
+// Rebuild uses the same selector.
+func NewWithSelector(metric Metric, items []interface{}, selector VantageSelector) (t *VPTree) {
+	t = newVPTree(metric, len(items))
+	t.selector = selector
+	t.root = t.buildFromPoints(items, 0)
+	return
+}
+
+// selectVantage returns the index of the item to use as a vantage point.
+func (vp *VPTree) selectVantage(items []interface{}) int {
+	if vp.selector == nil {
+		return SelectorRandom(items, vp.distanceMetric, vp.rnd)
+	}
+	return vp.selector(items, vp.distanceMetric, vp.rnd)
+}
@@ -0,0 +1,130 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+type Vector []float64
+
+func VectorMetric(a, b interface{}) float64 {
+	v1, v2 := a.(Vector), b.(Vector)
+
+	sum := 0.0
+	for i := range v1 {
+		sum += (v1[i] - v2[i]) * (v1[i] - v2[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// uniformVectors returns n vectors distributed uniformly in the unit cube
+func uniformVectors(r *rand.Rand, n, dim int) []interface{} {
+	items := make([]interface{}, n)
+	for i := range items {
+		v := make(Vector, dim)
+		for j := range v {
+			v[j] = r.Float64()
+		}
+		items[i] = v
+	}
+	return items
+}
+
+// clusteredVectors returns n vectors distributed normally around a few
+// random cluster centers in the unit cube
+func clusteredVectors(r *rand.Rand, n, dim, clusters int) []interface{} {
+	centers := uniformVectors(r, clusters, dim)
+
+	items := make([]interface{}, n)
+	for i := range items {
+		c := centers[r.Intn(clusters)].(Vector)
+		v := make(Vector, dim)
+		for j := range v {
+			v[j] = c[j] + r.NormFloat64()*0.02
+		}
+		items[i] = v
+	}
+	return items
+}
+
+var selectors = []struct {
+	name     string
+	selector VantageSelector
+}{
+	{"Random", SelectorRandom},
+	{"Spread", SelectorSpread},
+	{"Center", SelectorCenter},
+	{"MinSearchCost", SelectorMinSearchCost},
+}
+
+// This test makes sure all selectors build trees that search correctly
+func TestSelectors(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	items := clusteredVectors(r, 1000, 4, 10)
+
+	for _, s := range selectors {
+		vp := NewWithSelector(VectorMetric, append([]interface{}(nil), items...), s.selector)
+		vp.Rebuild()
+
+		for i := 0; i < 10; i++ {
+			q := items[r.Intn(len(items))]
+			results, distances := vp.Search(q, 10)
+			expected, expectedDists := BruteForceSearch(VectorMetric, items, q, 10)
+
+			if len(results) != len(expected) {
+				t.Fatalf("%v: expected %v results, got %v", s.name, len(expected), len(results))
+			}
+			for j := range distances {
+				if distances[j] != expectedDists[j] {
+					t.Errorf("%v: expected distances[%v] to be %v, got %v", s.name, j, expectedDists[j], distances[j])
+				}
+			}
+		}
+	}
+}
+
+func benchmarkSelectors(b *testing.B, items []interface{}) {
+	r := rand.New(rand.NewSource(2))
+	queries := make([]interface{}, 100)
+	for i := range queries {
+		q := append(Vector(nil), items[r.Intn(len(items))].(Vector)...)
+		for j := range q {
+			q[j] += r.NormFloat64() * 0.01
+		}
+		queries[i] = q
+	}
+
+	for _, s := range selectors {
+		b.Run(s.name, func(b *testing.B) {
+			// Average over a few trees built from fixed seeds, so that the
+			// results are reproducible and less subject to chance
+			trees := make([]*VPTree, 4)
+			for i := range trees {
+				vp := newVPTree(VectorMetric, len(items))
+				vp.selector = s.selector
+				vp.src.Seed(int64(i + 1))
+				vp.root = vp.buildFromPoints(append([]interface{}(nil), items...), 0)
+				trees[i] = vp
+			}
+			p := SearchParameters{K: 10}
+
+			nodes := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				vp := trees[i%len(trees)]
+				_, _, stats := vp.SearchWithStats(queries[i/len(trees)%len(queries)], p)
+				nodes += stats.NodesVisited
+			}
+			b.ReportMetric(float64(nodes)/float64(b.N), "nodes/op")
+		})
+	}
+}
+
+func BenchmarkSelectorsUniform(b *testing.B) {
+	benchmarkSelectors(b, uniformVectors(rand.New(rand.NewSource(3)), 20000, 6))
+}
+
+func BenchmarkSelectorsClustered(b *testing.B) {
+	benchmarkSelectors(b, clusteredVectors(rand.New(rand.NewSource(3)), 20000, 6, 8))
+}
@@ -0,0 +1,107 @@
+package vptree
+
+import "sort"
+
+// Split partitions the items of the VP-tree into n groups of roughly equal
+// size and builds a VP-tree for each group, e.g. to serve a large dataset
+// from several machines. The groups follow the top levels of the tree, so
+// items that are close to each other tend to end up in the same shard.
+// SearchMulti searches all shards and merges their results.
+//
+// n is clamped to the range 1 to the number of items. The shards are built
+// with the same options as the VP-tree, except for quantization.
+func (vp *VPTree) Split(n int) []*VPTree {
+	// Every subtree is contiguous in the pre-order of Items, so contiguous
+	// ranges of it are made up of few subtrees.
+	items := vp.Items()
+	if n > len(items) {
+		n = len(items)
+	}
+	if n < 1 {
+		n = 1
+	}
+
+	shards := make([]*VPTree, n)
+	for i := range shards {
+		group := items[i*len(items)/n : (i+1)*len(items)/n]
+
+		t := newVPTree(vp.distanceMetric, len(group))
+		t.balanceFactor = vp.balanceFactor
+		t.selector = vp.selector
+		t.root = t.buildFromPoints(group, 0)
+		shards[i] = t
+	}
+
+	return shards
+}
+
+// SearchMulti searches all the given VP-trees for the nearest neighbours of
+// target and merges their results, so that searching the shards returned by
+// Split finds the same neighbours as searching the original tree. p applies
+// to the search as a whole, except for MaxNodes, which limits the search of
+// each tree separately.
+func SearchMulti(trees []*VPTree, target interface{}, p SearchParameters) (results []interface{}, distances []float64) {
+	if p.K < 1 {
+		return
+	}
+
+	// Quantize the target once, and look up ids, resolve payloads and report
+	// results only for the merged results, so that Less ranks the items
+	// themselves, like in a search of a single tree
+	if p.QuantizeTarget != nil {
+		target = p.QuantizeTarget(target)
+	}
+	shardParams := p
+	shardParams.QuantizeTarget, shardParams.IDLookup = nil, nil
+	shardParams.OnResult, shardParams.PayloadResolver = nil, nil
+
+	merged := &itemsByDistance{}
+	for _, t := range trees {
+		items, dists := t.SearchWithParameters(target, shardParams)
+		merged.items = append(merged.items, items...)
+		merged.dists = append(merged.dists, dists...)
+	}
+	if p.Less != nil {
+		sort.Stable(rankedItems{merged, p.Less})
+	} else {
+		sort.Stable(merged)
+	}
+
+	if merged.Len() > p.K {
+		merged.items, merged.dists = merged.items[:p.K], merged.dists[:p.K]
+	}
+	results, distances = merged.items, merged.dists
+
+	if p.IDLookup != nil {
+		for i := range results {
+			results[i] = lookupID(p.IDLookup, results[i])
+		}
+	}
+	if p.PayloadResolver != nil {
+		for i := range results {
+			results[i] = p.PayloadResolver(results[i])
+		}
+	}
+
+	if p.OnResult != nil {
+		for i := range results {
+			p.OnResult(target, results[i], distances[i], i)
+		}
+	}
+
+	return
+}
+
+// rankedItems sorts items by their precomputed distances, and items at the
+// same distance by less; see SearchParameters.Less.
+type rankedItems struct {
+	*itemsByDistance
+	less func(a, b Neighbor) bool
+}
+
+func (s rankedItems) Less(i, j int) bool {
+	if s.dists[i] != s.dists[j] {
+		return s.dists[i] < s.dists[j]
+	}
+	return s.less(Neighbor{s.items[i], s.dists[i]}, Neighbor{s.items[j], s.dists[j]})
+}
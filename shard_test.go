@@ -0,0 +1,140 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// This test makes sure searching the shards of a tree finds the same
+// neighbours as searching the tree itself
+func TestSplit(t *testing.T) {
+	vp := newRandomCoordinateTree(3000)
+
+	for _, n := range []int{1, 3, 8} {
+		shards := vp.Split(n)
+		if len(shards) != n {
+			t.Fatalf("Expected %v shards, got %v", n, len(shards))
+		}
+
+		seen := make(map[interface{}]bool)
+		for _, shard := range shards {
+			if shard.size < 3000/n-1 || shard.size > 3000/n+1 {
+				t.Errorf("Expected a shard of about %v items, got %v", 3000/n, shard.size)
+			}
+			for _, item := range shard.Items() {
+				if seen[item] {
+					t.Errorf("Item %v is in more than one shard", item)
+				}
+				seen[item] = true
+			}
+		}
+		if len(seen) != 3000 {
+			t.Errorf("Expected the shards to hold 3000 items, got %v", len(seen))
+		}
+
+		for i := 0; i < 10; i++ {
+			q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+			expected, expectedDists := vp.Search(q, 10)
+			results, distances := SearchMulti(shards, q, SearchParameters{K: 10})
+			compareResults(t, results, distances, expected, expectedDists)
+		}
+	}
+
+	if shards := New(CoordinateMetric, []interface{}{Coordinate{}}).Split(4); len(shards) != 1 {
+		t.Errorf("Expected a single shard for a single item, got %v", len(shards))
+	}
+}
+
+// This test makes sure SearchMulti breaks ties between the shards with Less,
+// like a search of the whole tree
+func TestSearchMultiLess(t *testing.T) {
+	type version struct {
+		X, ID int
+	}
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(float64(a.(version).X - b.(version).X))
+	}
+
+	var items []interface{}
+	for x := 0; x < 10; x++ {
+		for i := 0; i < 8; i++ {
+			items = append(items, version{x, len(items)})
+		}
+	}
+	vp := New(metric, append([]interface{}(nil), items...))
+	shards := vp.Split(4)
+
+	// Newest first
+	less := func(a, b Neighbor) bool {
+		return a.Item.(version).ID > b.Item.(version).ID
+	}
+
+	for _, k := range []int{1, 3, 8, 13} {
+		q := version{X: rand.Intn(10)}
+		p := SearchParameters{K: k, Less: less}
+
+		expected, expectedDists := vp.SearchWithParameters(q, p)
+		results, distances := SearchMulti(shards, q, p)
+		if !reflect.DeepEqual(results, expected) || !reflect.DeepEqual(distances, expectedDists) {
+			t.Errorf("k %v: expected %v, got %v", k, expected, results)
+		}
+	}
+}
+
+// This test makes sure SearchMulti ranks the items of the shards with Less
+// before looking up their ids, and reports results for the quantized target,
+// like a search of the whole tree
+func TestSearchMultiIDLookup(t *testing.T) {
+	points := make([]int, 80)
+	ids := make([]interface{}, len(points))
+	items := make([]interface{}, len(points))
+	for i := range points {
+		points[i] = i / 8
+		ids[i] = fmt.Sprintf("id%v", i)
+		items[i] = i
+	}
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(float64(points[a.(int)] - points[b.(int)]))
+	}
+	vp := New(metric, append([]interface{}(nil), items...))
+	shards := vp.Split(4)
+
+	type call struct {
+		target, result interface{}
+		rank           int
+	}
+	var expectedCalls, calls []call
+	p := SearchParameters{
+		K:        13,
+		IDLookup: ids,
+		Less: func(a, b Neighbor) bool {
+			return a.Item.(int) > b.Item.(int)
+		},
+		QuantizeTarget: func(target interface{}) interface{} {
+			return target.(int) / 8 * 8
+		},
+	}
+
+	for i := 0; i < 10; i++ {
+		q := rand.Intn(len(points))
+
+		p.OnResult = func(target, result interface{}, dist float64, rank int) {
+			expectedCalls = append(expectedCalls, call{target, result, rank})
+		}
+		expected, expectedDists := vp.SearchWithParameters(q, p)
+		p.OnResult = func(target, result interface{}, dist float64, rank int) {
+			calls = append(calls, call{target, result, rank})
+		}
+		results, distances := SearchMulti(shards, q, p)
+
+		if !reflect.DeepEqual(results, expected) || !reflect.DeepEqual(distances, expectedDists) {
+			t.Errorf("expected %v, got %v", expected, results)
+		}
+	}
+	if !reflect.DeepEqual(calls, expectedCalls) {
+		t.Errorf("expected OnResult calls %v, got %v", expectedCalls, calls)
+	}
+}
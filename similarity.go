@@ -0,0 +1,95 @@
+package vptree
+
+// StructuralSimilarity measures how similarly two VP-trees over the same items
+// partition them, e.g. to see how much the choice of vantage points varies
+// between builds with different seeds or selectors. It returns a value
+// between 0 and 1, which is 1 if both trees keep the same pairs of items in
+// the same subtrees for equally long.
+//
+// Every pair of items scores the subtrees below the root that contain both of
+// them, each weighted by the inverse of its size, since small subtrees are
+// unlikely to share items by chance. The similarity is the sum over all pairs
+// of the smaller of the two trees' scores, divided by the sum of the larger
+// one. Items of a that equal reports as equal to none of the items of b are
+// ignored.
+//
+// Matching the items with equal and comparing all pairs takes time quadratic
+// in the number of items, so this is meant for experiments on samples of
+// moderate size.
+func StructuralSimilarity(a, b *VPTree, equal func(x, y interface{}) bool) float64 {
+	pathsA, itemsA := a.paths()
+	pathsB, itemsB := b.paths()
+
+	// Pair up the paths of equal items
+	var matchedA, matchedB []treePath
+	used := make([]bool, len(itemsB))
+	for i, x := range itemsA {
+		for j, y := range itemsB {
+			if !used[j] && equal(x, y) {
+				used[j] = true
+				matchedA = append(matchedA, pathsA[i])
+				matchedB = append(matchedB, pathsB[j])
+				break
+			}
+		}
+	}
+
+	var common, total float64
+	for i := range matchedA {
+		for j := i + 1; j < len(matchedA); j++ {
+			sa := matchedA[i].shared(matchedA[j])
+			sb := matchedB[i].shared(matchedB[j])
+			common += min(sa, sb)
+			total += max(sa, sb)
+		}
+	}
+
+	if total == 0 {
+		return 1
+	}
+	return common / total
+}
+
+// A treePath holds the nodes on the path from the root of a VP-tree to an
+// item, not counting the root, and for each number of leading nodes of the
+// path the sum of their inverse sizes.
+type treePath struct {
+	nodes   []*node
+	weights []float64
+}
+
+// shared returns the sum of the inverse sizes of the nodes p and q have in
+// common.
+func (p treePath) shared(q treePath) float64 {
+	i := 0
+	for i < len(p.nodes) && i < len(q.nodes) && p.nodes[i] == q.nodes[i] {
+		i++
+	}
+	return p.weights[i]
+}
+
+// paths returns the items of the VP-tree and their paths from the root.
+func (vp *VPTree) paths() (paths []treePath, items []interface{}) {
+	var visit func(n *node, path treePath)
+	visit = func(n *node, path treePath) {
+		if n == nil {
+			return
+		}
+		paths = append(paths, path)
+		items = append(items, n.Item)
+
+		for _, c := range append([]*node{n.Left, n.Right}, n.Bucket...) {
+			if c == nil {
+				continue
+			}
+			weight := path.weights[len(path.weights)-1] + 1/float64(max(c.Size, 1))
+			visit(c, treePath{
+				nodes:   append(append([]*node(nil), path.nodes...), c),
+				weights: append(append([]float64(nil), path.weights...), weight),
+			})
+		}
+	}
+	visit(vp.root, treePath{weights: []float64{0}})
+
+	return
+}
@@ -0,0 +1,33 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure a tree is perfectly similar to itself, but not to a
+// tree whose items are matched up at random
+func TestStructuralSimilarity(t *testing.T) {
+	items := randomCoordinates(500)
+	equal := func(x, y interface{}) bool {
+		return x == y
+	}
+
+	vp := New(CoordinateMetric, append([]interface{}(nil), items...))
+	if s := StructuralSimilarity(vp, vp, equal); s != 1 {
+		t.Errorf("expected a tree to have a similarity of 1 to itself, got %v", s)
+	}
+
+	// Matching every item with a random other one scrambles the partition
+	perm := rand.Perm(len(items))
+	index := make(map[interface{}]int)
+	for i, item := range items {
+		index[item] = i
+	}
+	scrambled := func(x, y interface{}) bool {
+		return items[perm[index[x]]] == y
+	}
+	if s := StructuralSimilarity(vp, vp, scrambled); s > 0.3 {
+		t.Errorf("expected a scrambled tree to have a low similarity, got %v", s)
+	}
+}
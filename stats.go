@@ -0,0 +1,136 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+)
+
+// degenerateFactor is how many times deeper than a perfectly balanced tree a
+// VP-tree has to be for IsDegenerate to report it.
+const degenerateFactor = 4
+
+// TreeStats describes the shape of a VP-tree.
+type TreeStats struct {
+	// Size is the number of items in the tree.
+	Size int
+
+	// Leaves is the number of nodes without children.
+	Leaves int
+
+	// MaxDepth is the number of nodes on the longest path from the root to
+	// a leaf.
+	MaxDepth int
+
+	// AvgDepth is the average number of nodes on the path from the root to
+	// an item.
+	AvgDepth float64
+}
+
+// Stats returns statistics about the shape of the VP-tree.
+func (vp *VPTree) Stats() (stats TreeStats) {
+	var depthSum int
+
+	var walk func(n *node, depth int)
+	walk = func(n *node, depth int) {
+		if n == nil {
+			return
+		}
+
+		stats.Size++
+		depthSum += depth
+		if depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+		if n.Left == nil && n.Right == nil {
+			stats.Leaves++
+		}
+
+		walk(n.Left, depth+1)
+		walk(n.Right, depth+1)
+		for _, b := range n.Bucket {
+			walk(b, depth+1)
+		}
+	}
+	walk(vp.root, 1)
+
+	if stats.Size > 0 {
+		stats.AvgDepth = float64(depthSum) / float64(stats.Size)
+	}
+
+	return
+}
+
+// IsDegenerate reports whether the VP-tree is so unbalanced that it should be
+// rebuilt, which is the case if it is more than four times as deep as a
+// perfectly balanced tree with the same number of items. This can happen
+// after many inserts or on pathological data.
+func (vp *VPTree) IsDegenerate() bool {
+	stats := vp.Stats()
+	if stats.Size == 0 {
+		return false
+	}
+
+	ideal := math.Ceil(math.Log2(float64(stats.Size + 1)))
+	return float64(stats.MaxDepth) > degenerateFactor*ideal
+}
+
+// Rebuild rebuilds the VP-tree from its items, which rebalances it.
+func (vp *VPTree) Rebuild() {
+	vp.root = vp.buildFromPoints(vp.Items(), 0)
+	vp.coarse = nil
+	vp.epoch++
+}
+
+// Validate checks the invariants of the VP-tree and returns an error
+// describing the first violation it finds, or nil. It checks that every item
+// is on the correct side of each of its ancestors' thresholds, that the
+// bounding radii and sizes of every node are exact, and that the tree holds
+// as many items as it should. It evaluates the metric for every item and each
+// of its ancestors, so it is meant for tests and debugging.
+func (vp *VPTree) Validate() error {
+	var err error
+	size := 0
+
+	vp.root.walk(func(n *node) {
+		size++
+		if err != nil {
+			return
+		}
+
+		subtreeSize := 0
+		n.walk(func(*node) { subtreeSize++ })
+		if n.Size != subtreeSize {
+			err = fmt.Errorf("vptree: subtree of %v holds %v items, but its size is %v", n.Item, subtreeSize, n.Size)
+			return
+		}
+
+		for _, side := range []struct {
+			name   string
+			child  *node
+			radius float64
+		}{{"left", n.Left, n.LeftRadius}, {"right", n.Right, n.RightRadius}} {
+			max := 0.0
+			side.child.walk(func(c *node) {
+				dist := vp.distanceMetric(n.Item, c.Item)
+				max = math.Max(max, dist)
+
+				if err == nil && side.child == n.Left && dist > vp.leftBound(n) {
+					err = fmt.Errorf("vptree: item %v is %v away from %v, beyond the threshold %v of its left subtree", c.Item, dist, n.Item, n.Threshold)
+				}
+				if err == nil && side.child == n.Right && dist < n.Threshold {
+					err = fmt.Errorf("vptree: item %v is %v away from %v, within the threshold %v of its right subtree", c.Item, dist, n.Item, n.Threshold)
+				}
+			})
+
+			if err == nil && side.radius != max {
+				err = fmt.Errorf("vptree: %v radius of %v is %v, but should be %v", side.name, n.Item, side.radius, max)
+			}
+		}
+	})
+
+	if err == nil && size != vp.size {
+		err = fmt.Errorf("vptree: tree holds %v items, but its size is %v", size, vp.size)
+	}
+
+	return err
+}
@@ -0,0 +1,89 @@
+package vptree
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// This test makes sure Stats describes a known tree
+func TestStats(t *testing.T) {
+	vp := New(absMetric, nil)
+	if stats := vp.Stats(); stats != (TreeStats{}) {
+		t.Errorf("Expected empty stats for an empty tree, got %+v", stats)
+	}
+
+	// Inserting sorted items into a VP-tree yields a chain
+	for i := 0; i < 4; i++ {
+		vp.Insert(float64(i))
+	}
+
+	expected := TreeStats{Size: 4, Leaves: 1, MaxDepth: 4, AvgDepth: 2.5}
+	if stats := vp.Stats(); stats != expected {
+		t.Errorf("Expected %+v, got %+v", expected, stats)
+	}
+}
+
+// This test makes sure IsDegenerate detects a chain, but not a balanced tree,
+// and that Rebuild fixes the chain
+func TestIsDegenerate(t *testing.T) {
+	const n = 500
+
+	chain := New(absMetric, nil)
+	vpitems := make([]interface{}, n)
+	for i := 0; i < n; i++ {
+		chain.Insert(float64(i))
+		vpitems[i] = rand.Float64() * n
+	}
+
+	if !chain.IsDegenerate() {
+		t.Errorf("Expected a chain of depth %v to be degenerate", chain.Stats().MaxDepth)
+	}
+
+	balanced := New(absMetric, vpitems)
+	if balanced.IsDegenerate() {
+		t.Errorf("Expected a balanced tree of depth %v not to be degenerate", balanced.Stats().MaxDepth)
+	}
+
+	chain.Rebuild()
+	if chain.IsDegenerate() {
+		t.Errorf("Expected a rebuilt tree of depth %v not to be degenerate", chain.Stats().MaxDepth)
+	}
+	if size := chain.Stats().Size; size != n {
+		t.Errorf("Expected the rebuilt tree to have %v items, got %v", n, size)
+	}
+}
+
+// This test makes sure Validate accepts built trees and detects broken
+// invariants
+func TestValidate(t *testing.T) {
+	vp := newRandomCoordinateTree(500)
+	if err := vp.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Break a node whose left subtree reaches beyond half its threshold. The
+	// root may not have one, since its threshold is not an exact median.
+	var n *node
+	vp.root.walk(func(c *node) {
+		if n == nil && c.Left != nil && c.LeftRadius > c.Threshold/2 {
+			n = c
+		}
+	})
+
+	n.LeftRadius /= 2
+	if err := vp.Validate(); err == nil {
+		t.Error("Expected Validate to detect a radius that is too small")
+	}
+	n.LeftRadius *= 2
+
+	n.Threshold /= 2
+	if err := vp.Validate(); err == nil {
+		t.Error("Expected Validate to detect a threshold that is too small")
+	}
+	n.Threshold *= 2
+
+	vp.size++
+	if err := vp.Validate(); err == nil {
+		t.Error("Expected Validate to detect a wrong size")
+	}
+}
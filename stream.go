@@ -0,0 +1,75 @@
+package vptree
+
+// A StreamSearcher searches a VP-tree for a stream of related targets, e.g. a
+// cursor that is dragged across the space, and suppresses results that were
+// already returned recently to reduce flicker. An item returned by one search
+// is excluded from the results of the next window-1 searches. A
+// StreamSearcher is not safe for concurrent use.
+type StreamSearcher struct {
+	tree   *VPTree
+	window int
+	equal  func(a, b interface{}) bool
+	recent [][]interface{}
+}
+
+// NewStreamSearcher creates a StreamSearcher that searches tree and suppresses
+// results over a window of the given number of searches. equal is used to
+// recognize items that were returned before; if it is nil, items are compared
+// with ==.
+func NewStreamSearcher(tree *VPTree, window int, equal func(a, b interface{}) bool) *StreamSearcher {
+	if equal == nil {
+		equal = func(a, b interface{}) bool { return a == b }
+	}
+
+	return &StreamSearcher{
+		tree:   tree,
+		window: window,
+		equal:  equal,
+	}
+}
+
+// Search searches the tree like VPTree.SearchWithParameters, but leaves out
+// the items returned by the previous window-1 searches.
+func (s *StreamSearcher) Search(target interface{}, p SearchParameters) (results []interface{}, distances []float64) {
+	exclude := p.Exclude
+	p.Exclude = func(item interface{}) bool {
+		if exclude != nil && exclude(item) {
+			return true
+		}
+		for _, rs := range s.recent {
+			for _, r := range rs {
+				if s.equal(item, r) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// Remember the items as stored in the tree, not the looked-up ids or the
+	// resolved payloads, so the resolver takes over the IDLookup
+	var returned []interface{}
+	ids, resolver := p.IDLookup, p.PayloadResolver
+	p.IDLookup = nil
+	p.PayloadResolver = func(key interface{}) interface{} {
+		returned = append(returned, key)
+		if ids != nil {
+			key = lookupID(ids, key)
+		}
+		if resolver != nil {
+			return resolver(key)
+		}
+		return key
+	}
+
+	results, distances = s.tree.SearchWithParameters(target, p)
+
+	if s.window > 1 {
+		s.recent = append(s.recent, returned)
+		if len(s.recent) > s.window-1 {
+			s.recent = s.recent[1:]
+		}
+	}
+
+	return
+}
@@ -0,0 +1,81 @@
+package vptree
+
+import "testing"
+
+// This test makes sure an item returned by query i is suppressed through query
+// i+W-1 and returned again afterwards
+func TestStreamSearcher(t *testing.T) {
+	vpitems := make([]interface{}, 100)
+	for i := range vpitems {
+		vpitems[i] = float64(i)
+	}
+	vp := New(absMetric, vpitems)
+
+	const window = 3
+	s := NewStreamSearcher(vp, window, nil)
+	p := SearchParameters{K: 1}
+
+	// Querying the same target repeatedly cycles through its neighbours
+	expected := []float64{50, 51, 49, 50, 51, 49, 50}
+	for i, e := range expected {
+		results, distances := s.Search(50.1, p)
+		if len(results) != 1 || results[0] != e {
+			t.Fatalf("Expected query %v to return %v, got %v", i, e, results)
+		}
+		if distances[0] != absMetric(50.1, e) {
+			t.Errorf("Expected distance %v, got %v", absMetric(50.1, e), distances[0])
+		}
+	}
+}
+
+// This test makes sure a window of 1 suppresses nothing and that Exclude is
+// still honored
+func TestStreamSearcherNoWindow(t *testing.T) {
+	vpitems := make([]interface{}, 100)
+	for i := range vpitems {
+		vpitems[i] = float64(i)
+	}
+	vp := New(absMetric, vpitems)
+
+	s := NewStreamSearcher(vp, 1, nil)
+	p := SearchParameters{
+		K:       1,
+		Exclude: func(item interface{}) bool { return item == 10.0 },
+	}
+
+	for i := 0; i < 3; i++ {
+		if results, _ := s.Search(10.2, p); len(results) != 1 || results[0] != 11.0 {
+			t.Errorf("Expected 11 to be returned, got %v", results)
+		}
+	}
+}
+
+// This test makes sure suppression still works when the results are mapped to
+// ids with IDLookup
+func TestStreamSearcherIDLookup(t *testing.T) {
+	points := make([]float64, 100)
+	ids := make([]interface{}, len(points))
+	vpitems := make([]interface{}, len(points))
+	for i := range points {
+		// Spread out a little, so that there are no ties
+		points[i] = float64(i) + float64(i*i)/10000
+		ids[i] = string(rune('a'+i%26)) + string(rune('0'+i/26))
+		vpitems[i] = i
+	}
+	metric := func(a, b interface{}) float64 {
+		return absMetric(points[a.(int)], points[b.(int)])
+	}
+	vp := New(metric, vpitems)
+
+	s := NewStreamSearcher(vp, 3, nil)
+	p := SearchParameters{K: 1, IDLookup: ids}
+
+	// The neighbours of 50 are 50, 49 and 51
+	expected := []int{50, 49, 51, 50}
+	for i, e := range expected {
+		results, _ := s.Search(50, p)
+		if len(results) != 1 || results[0] != ids[e] {
+			t.Fatalf("Expected query %v to return %v, got %v", i, ids[e], results)
+		}
+	}
+}
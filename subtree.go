@@ -0,0 +1,62 @@
+package vptree
+
+// The subtrees a result of SearchWithSubtrees can come from.
+const (
+	// SubtreeRoot is the root node itself, including its bucket.
+	SubtreeRoot = -1
+	// SubtreeLeft is the left subtree of the root, whose items are closer to
+	// the root than its threshold.
+	SubtreeLeft = 0
+	// SubtreeRight is the right subtree of the root.
+	SubtreeRight = 1
+)
+
+// SearchWithSubtrees is like SearchWithParameters, but also returns for every
+// result which subtree of the root it was found in: SubtreeLeft, SubtreeRight,
+// or SubtreeRoot for the root's own item. Counting these over many queries
+// shows whether the queries hit both halves of the tree evenly, e.g. before
+// splitting it into shards. The coarse index, if any, is not used.
+func (vp *VPTree) SearchWithSubtrees(target interface{}, p SearchParameters) (results []interface{}, distances []float64, subtrees []int) {
+	if p.K < 1 {
+		return
+	}
+
+	s := vp.newSearcher(target, p)
+	s.subtrees = make(map[*node]int)
+	s.branch = SubtreeRoot
+	s.search(vp.root, 0)
+
+	hits := s.drain()
+	subtrees = make([]int, len(hits))
+	for i, hi := range hits {
+		subtrees[i] = s.subtrees[hi.node]
+	}
+	results, distances = s.collect(hits)
+
+	return results, distances, subtrees
+}
+
+// SearchWithDepths is like SearchWithParameters, but also returns for every
+// result the depth of its node in the tree: 0 for the root, 1 for its
+// children, and so on. The nodes of a bucket are one level below the node
+// they belong to. This shows whether the neighbours of queries tend to live
+// near the top of the tree or in its leaves, e.g. to decide how much of the
+// tree to keep in a cache. The coarse index, if any, is not used.
+func (vp *VPTree) SearchWithDepths(target interface{}, p SearchParameters) (results []interface{}, distances []float64, depths []int) {
+	if p.K < 1 {
+		return
+	}
+
+	s := vp.newSearcher(target, p)
+	s.depths = make(map[*node]int)
+	s.search(vp.root, 0)
+
+	hits := s.drain()
+	depths = make([]int, len(hits))
+	for i, hi := range hits {
+		depths[i] = s.depths[hi.node]
+	}
+	results, distances = s.collect(hits)
+
+	return results, distances, depths
+}
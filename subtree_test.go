@@ -0,0 +1,161 @@
+package vptree
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// This test makes sure every result is tagged with the subtree of the root
+// it is in, and that the results match those of a plain search
+func TestSearchWithSubtrees(t *testing.T) {
+	vp := newRandomCoordinateTree(2000)
+
+	subtreeOf := map[interface{}]int{vp.root.Item: SubtreeRoot}
+	vp.root.Left.walk(func(n *node) {
+		subtreeOf[n.Item] = SubtreeLeft
+	})
+	vp.root.Right.walk(func(n *node) {
+		subtreeOf[n.Item] = SubtreeRight
+	})
+
+	seen := make(map[int]bool)
+	// Querying the roots of the subtrees finds items in both of them
+	queries := []interface{}{vp.root.Right.Item}
+	if vp.root.Left != nil {
+		queries = append(queries, vp.root.Left.Item)
+	}
+	for i := 0; i < 50; i++ {
+		queries = append(queries, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	for _, q := range queries {
+		results, distances, subtrees := vp.SearchWithSubtrees(q, SearchParameters{K: 20})
+		_, expected := vp.Search(q, 20)
+
+		if len(results) != len(expected) || len(subtrees) != len(results) {
+			t.Fatalf("expected %v results and subtrees, got %v and %v", len(expected), len(results), len(subtrees))
+		}
+		for j := range results {
+			if distances[j] != expected[j] {
+				t.Errorf("expected distances[%v] to be %v, got %v", j, expected[j], distances[j])
+			}
+			if want := subtreeOf[results[j]]; subtrees[j] != want {
+				t.Errorf("expected %v to be in subtree %v, got %v", results[j], want, subtrees[j])
+			}
+			seen[subtrees[j]] = true
+		}
+	}
+
+	if (vp.root.Left != nil && !seen[SubtreeLeft]) || !seen[SubtreeRight] {
+		t.Errorf("expected results from both subtrees, got %v", seen)
+	}
+
+	results, distances, subtrees := vp.SearchWithSubtrees(vp.root.Item, SearchParameters{K: 1})
+	if len(results) != 1 || distances[0] != 0 || subtrees[0] != SubtreeRoot {
+		t.Errorf("expected the root to be tagged %v, got %v", SubtreeRoot, subtrees)
+	}
+}
+
+// This test makes sure MaxSubtrees limits the subtrees a search descends into,
+// and that a limit that is never reached gives exact results
+func TestMaxSubtrees(t *testing.T) {
+	vp := newRandomCoordinateTree(2000)
+
+	// The nodes above depth 3 and the 2 largest subtrees at depth 3
+	var sizes []int
+	bound := 0
+	var count func(n *node, depth int)
+	count = func(n *node, depth int) {
+		switch {
+		case n == nil:
+		case depth == 3:
+			sizes = append(sizes, n.Size)
+		default:
+			bound++
+			count(n.Left, depth+1)
+			count(n.Right, depth+1)
+		}
+	}
+	count(vp.root, 0)
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+	bound += sizes[0] + sizes[1]
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+		_, expected, full := vp.SearchWithStats(q, SearchParameters{K: 10})
+		_, distances, stats := vp.SearchWithStats(q, SearchParameters{K: 10, MaxSubtrees: 1 << 20, SubtreeDepth: 4})
+		if len(distances) != len(expected) || stats.NodesVisited != full.NodesVisited {
+			t.Fatalf("expected an unbounded search to visit %v nodes, got %v", full.NodesVisited, stats.NodesVisited)
+		}
+		for j := range distances {
+			if distances[j] != expected[j] {
+				t.Errorf("expected distances[%v] to be %v, got %v", j, expected[j], distances[j])
+			}
+		}
+
+		// Without pruning, a search visits all subtrees of the root unless
+		// limited to one of them
+		_, _, subtrees := vp.SearchWithSubtrees(q, SearchParameters{K: vp.size, MaxSubtrees: 1})
+		side := SubtreeRoot
+		for _, st := range subtrees {
+			if st != SubtreeRoot && side != SubtreeRoot && st != side {
+				t.Fatalf("expected results from a single subtree of the root, got %v", subtrees)
+			}
+			if st != SubtreeRoot {
+				side = st
+			}
+		}
+
+		// Deeper subtrees are smaller
+		_, _, limited := vp.SearchWithStats(q, SearchParameters{K: vp.size, MaxSubtrees: 2, SubtreeDepth: 3})
+		if limited.NodesVisited > bound || math.IsInf(limited.MinPrunedDistance, 1) {
+			t.Errorf("expected a search of 2 subtrees at depth 3 to visit at most %v nodes, got %+v", bound, limited)
+		}
+	}
+}
+
+// This test makes sure SearchWithDepths reports the depth of every result's
+// node, on a small tree built by hand
+func TestSearchWithDepths(t *testing.T) {
+	vp := newVPTree(absMetric, 6)
+	vp.root = &node{
+		Item:        5.0,
+		Threshold:   3,
+		LeftRadius:  3,
+		RightRadius: 6,
+		Size:        6,
+		Left: &node{
+			Item:        3.0,
+			Threshold:   1,
+			RightRadius: 1,
+			Size:        2,
+			Right:       &node{Item: 2.0, Size: 1},
+		},
+		Right: &node{
+			Item:   10.0,
+			Size:   3,
+			Bucket: []*node{{Item: 11.0, Size: 1}, {Item: 12.0, Size: 1}},
+		},
+	}
+
+	depthOf := map[float64]int{5: 0, 3: 1, 2: 2, 10: 1, 11: 2, 12: 2}
+	for _, q := range []float64{0, 2.5, 5, 9, 12.5} {
+		results, distances, depths := vp.SearchWithDepths(q, SearchParameters{K: 6})
+		if len(results) != 6 || len(depths) != 6 {
+			t.Fatalf("query %v: expected 6 results, got %v", q, results)
+		}
+
+		for i, item := range results {
+			if distances[i] != math.Abs(item.(float64)-q) {
+				t.Errorf("query %v: expected %v at distance %v, got %v", q, item, math.Abs(item.(float64)-q), distances[i])
+			}
+			expected := depthOf[item.(float64)]
+			if depths[i] != expected {
+				t.Errorf("query %v: expected %v at depth %v, got %v", q, item, expected, depths[i])
+			}
+		}
+	}
+}
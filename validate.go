@@ -0,0 +1,82 @@
+package vptree
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// maxReportedItems is the number of invalid items an InvalidItemsError lists
+// in its message.
+const maxReportedItems = 5
+
+// An InvalidItemsError is returned by NewValidated if some items are invalid.
+type InvalidItemsError struct {
+	// Indices are the positions of the invalid items in the items slice.
+	Indices []int
+
+	// Items are the invalid items themselves.
+	Items []interface{}
+}
+
+func (e *InvalidItemsError) Error() string {
+	var b strings.Builder
+	b.WriteString("vptree: invalid items:")
+	for i := range e.Items {
+		if i == maxReportedItems {
+			fmt.Fprintf(&b, " and %v more", len(e.Items)-i)
+			break
+		}
+		fmt.Fprintf(&b, " %v at index %v", e.Items[i], e.Indices[i])
+		if i < len(e.Items)-1 {
+			b.WriteByte(',')
+		}
+	}
+	return b.String()
+}
+
+// NewValidated is like New, but first checks every item with valid and
+// returns an *InvalidItemsError listing the items it rejects instead of
+// building a tree from them. A single invalid item, e.g. a vector with a NaN
+// coordinate, makes every distance to it NaN, which silently breaks the
+// partitioning of the whole tree. If valid is nil, ValidFloats is used.
+func NewValidated(metric Metric, items []interface{}, valid func(item interface{}) bool) (*VPTree, error) {
+	if valid == nil {
+		valid = ValidFloats
+	}
+
+	var invalid InvalidItemsError
+	for i, item := range items {
+		if !valid(item) {
+			invalid.Indices = append(invalid.Indices, i)
+			invalid.Items = append(invalid.Items, item)
+		}
+	}
+	if len(invalid.Items) > 0 {
+		return nil, &invalid
+	}
+
+	return New(metric, items), nil
+}
+
+// ValidFloats reports whether item, a float64, []float64 or []float32, is
+// free of NaNs and infinities. Items of other types are considered valid.
+func ValidFloats(item interface{}) bool {
+	switch v := item.(type) {
+	case float64:
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	case []float64:
+		for _, x := range v {
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				return false
+			}
+		}
+	case []float32:
+		for _, x := range v {
+			if x != x || math.IsInf(float64(x), 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
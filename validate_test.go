@@ -0,0 +1,78 @@
+package vptree
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func euclideanMetric(a, b interface{}) float64 {
+	v1, v2 := a.([]float64), b.([]float64)
+
+	sum := 0.0
+	for i := range v1 {
+		sum += (v1[i] - v2[i]) * (v1[i] - v2[i])
+	}
+	return math.Sqrt(sum)
+}
+
+// This test makes sure NewValidated rejects a vector with a NaN coordinate
+// and identifies it
+func TestNewValidated(t *testing.T) {
+	items := []interface{}{
+		[]float64{0, 0},
+		[]float64{1, 0},
+		[]float64{0, math.NaN()},
+		[]float64{1, 1},
+	}
+
+	vp, err := NewValidated(euclideanMetric, items, nil)
+	if vp != nil || err == nil {
+		t.Fatal("expected the items to be rejected")
+	}
+
+	var invalid *InvalidItemsError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected an *InvalidItemsError, got %T", err)
+	}
+	if len(invalid.Indices) != 1 || invalid.Indices[0] != 2 {
+		t.Errorf("expected the item at index 2 to be reported, got %v", invalid.Indices)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "[0 NaN] at index 2") {
+		t.Errorf("expected the message to identify the item, got %q", msg)
+	}
+
+	items[2] = []float64{0, 1}
+	vp, err = NewValidated(euclideanMetric, items, nil)
+	if err != nil || vp.size != 4 {
+		t.Fatalf("expected a tree of 4 items, got %v, %v", vp, err)
+	}
+
+	// A custom check
+	_, err = NewValidated(absMetric, []interface{}{1.0, -1.0, 2.0, -3.0}, func(item interface{}) bool {
+		return item.(float64) >= 0
+	})
+	if !errors.As(err, &invalid) || len(invalid.Items) != 2 || invalid.Items[1] != -3.0 {
+		t.Errorf("expected the negative items to be reported, got %v", err)
+	}
+}
+
+func TestValidFloats(t *testing.T) {
+	for _, test := range []struct {
+		item  interface{}
+		valid bool
+	}{
+		{1.0, true},
+		{math.NaN(), false},
+		{math.Inf(-1), false},
+		{[]float64{1, 2}, true},
+		{[]float64{1, math.Inf(1)}, false},
+		{[]float32{1, float32(math.NaN())}, false},
+		{"not a float", true},
+	} {
+		if valid := ValidFloats(test.item); valid != test.valid {
+			t.Errorf("expected ValidFloats(%v) to be %v", test.item, test.valid)
+		}
+	}
+}
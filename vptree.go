@@ -4,6 +4,8 @@ import (
 	"container/heap"
 	"math"
 	"math/rand"
+	"sort"
+	"time"
 )
 
 type node struct {
@@ -11,143 +13,739 @@ type node struct {
 	Threshold float64
 	Left      *node
 	Right     *node
+
+	// LeftRadius and RightRadius are the largest distances from Item to any
+	// item in the Left and Right subtrees, respectively. They let searches
+	// prune subtrees that lie entirely too close to Item.
+	LeftRadius  float64
+	RightRadius float64
+
+	// Size is the number of items in the subtree rooted at this node,
+	// including its own item and those in its bucket.
+	Size int
+
+	// Bucket holds additional leaves that are not partitioned by Threshold
+	// and are always searched linearly; see Compact.
+	Bucket []*node
 }
 
 type heapItem struct {
 	Item interface{}
 	Dist float64
+	node *node
 }
 
 // A Metric is a function that measures the distance between two provided
 // interface{}-values. The function *must* be a metric in the mathematical
 // sense, that is, the metric d must fullfill the following requirements:
 //
-//	* d(x, y) >= 0
-//	* d(x, y) = 0 if and only if x = y
-//	* d(x, y) = d(y, x)
-//	* d(x, z) <= d(x, y) + d(y, z) (triangle inequality)
+//   - d(x, y) >= 0
+//   - d(x, y) = 0 if and only if x = y
+//   - d(x, y) = d(y, x)
+//   - d(x, z) <= d(x, y) + d(y, z) (triangle inequality)
 type Metric func(a, b interface{}) float64
 
 // A VPTree struct represents a Vantage-point tree. Vantage-point trees are
 // useful for nearest-neighbour searches in high-dimensional metric spaces.
 type VPTree struct {
 	root           *node
+	size           int
 	distanceMetric Metric
+	balanceFactor  float64
+	buildStats     *BuildStats
+	onBuildEvent   func(BuildEvent)
+	selector       VantageSelector
+	rnd            *rand.Rand
+	src            *randSource
+	auto           *autoModel
+
+	// arena, if not empty, provides the nodes for the next build; see
+	// NewWithArena.
+	arena []node
+
+	// coarse, if set, guides searches; see BuildCoarseIndex.
+	coarse *coarseIndex
+
+	// epoch counts the modifications of the tree; see Epoch.
+	epoch uint64
+
+	// dups, if set, holds the duplicates collapsed by NewDeduplicated.
+	dups *duplicateIndex
+
+	// If quantStep is positive, all thresholds lie on the grid
+	// quantMin + i*quantStep for 0 <= i <= quantMax; see NewQuantized.
+	quantMin  float64
+	quantStep float64
+	quantMax  int
 }
 
 // New creates a new VP-tree using the metric and items provided. The metric
 // measures the distance between two items, so that the VP-tree can find the
 // nearest neighbour(s) of a target item.
 func New(metric Metric, items []interface{}) (t *VPTree) {
-	t = &VPTree{
+	t = newVPTree(metric, len(items))
+	t.root = t.buildFromPoints(items, 0)
+	return
+}
+
+// newVPTree returns an empty VP-tree that is ready to be built.
+func newVPTree(metric Metric, size int) *VPTree {
+	src := newRandSource()
+	return &VPTree{
+		size:           size,
 		distanceMetric: metric,
+		rnd:            rand.New(src),
+		src:            src,
+		auto:           &autoModel{},
 	}
-	t.root = t.buildFromPoints(items)
+}
+
+// NewBalancedFactor is like New, but guarantees that the two subtrees of every
+// node differ in size by at most the given factor (or by one item, for very
+// small subtrees). The median split used by New can be badly skewed when many
+// items share the same distance to a vantage point; NewBalancedFactor instead
+// splits groups of equidistant items between the subtrees as needed, which
+// bounds the depth of the tree. Factors below 1 are treated as 1.
+func NewBalancedFactor(metric Metric, items []interface{}, factor float64) (t *VPTree) {
+	t = newVPTree(metric, len(items))
+	t.balanceFactor = math.Max(factor, 1)
+	t.root = t.buildFromPoints(items, 0)
 	return
 }
 
+// SearchParameters configures a search of the VP-tree.
+type SearchParameters struct {
+	// K is the maximum number of neighbours to return.
+	K int
+
+	// MaxDistance, if positive, restricts the search to items that are at
+	// most MaxDistance away from the target.
+	MaxDistance float64
+
+	// OnResult, if set, is called once for every returned result with its
+	// distance to the target and its rank (starting at 0) among the results.
+	// It is intended for logging feedback pairs, e.g. for metric learning.
+	OnResult func(target, result interface{}, dist float64, rank int)
+
+	// PayloadResolver, if set, is applied to every returned item. This
+	// allows the tree to store lightweight keys while searches return the
+	// full payloads, e.g. fetched from an external store. It is only called
+	// for the items that end up in the results.
+	PayloadResolver func(key interface{}) interface{}
+
+	// IDLookup, if set, maps the items of a tree of int indices, e.g. into
+	// a slice of the actual items, to the ids or labels they stand for:
+	// every returned index i is replaced by IDLookup[i]. Items that are not
+	// ints, or are out of range, are returned as nil. PayloadResolver, if
+	// set, is applied to the looked-up ids.
+	IDLookup []interface{}
+
+	// MaxNodes, if positive, limits how many nodes the search may visit.
+	// Once the limit is reached, the search returns the best results found
+	// so far, which may not be the true nearest neighbours. The coarse
+	// index, if any, is not used for such searches.
+	MaxNodes int
+
+	// MaxSubtrees, if positive, limits how many distinct subtrees at depth
+	// SubtreeDepth the search may descend into, e.g. to bound the number of
+	// regions, or shards, a query touches. Once the limit is reached, the
+	// search returns the best results found so far, like with MaxNodes.
+	// Subtrees are entered nearest first, as usual, and pruned subtrees do
+	// not count. The coarse index, if any, is not used for such searches.
+	MaxSubtrees int
+
+	// SubtreeDepth is the depth of the subtrees MaxSubtrees counts; the root
+	// is at depth 0. Values below 1 mean 1, i.e. the subtrees of the root.
+	SubtreeDepth int
+
+	// Exclude, if set, is called for candidate items, and items for which
+	// it returns true are left out of the results.
+	Exclude func(item interface{}) bool
+
+	// QuantizeTarget, if set, maps the target to a canonical representative,
+	// e.g. by snapping it to a grid, and the search uses that instead.
+	// Near-identical noisy targets that map to the same representative then
+	// get identical results. The results are still items of the tree, but
+	// their distances are measured to the representative, not to the
+	// original target. A CachingVPTree does not cache such searches; use its
+	// Quantize to share cache entries between near-identical targets.
+	QuantizeTarget func(target interface{}) interface{}
+
+	// ExpandDuplicates, for a tree built with NewDeduplicated, replaces
+	// every result with all the duplicates that were collapsed into it, at
+	// the same distance, still returning at most K results. Exclude only
+	// applies to the items kept in the tree, not their duplicates.
+	ExpandDuplicates bool
+
+	// OnPruneDecision, if set, is called for every node with children the
+	// search visits, once it has decided which of them to search. nodeItem
+	// is the vantage point, dist its distance to the target, threshold the
+	// distance that separates its subtrees, and visitedLeft and visitedRight
+	// report which subtrees were searched; missing subtrees count as not
+	// searched. tau is the search radius the last of the two decisions was
+	// based on: a subtree is pruned if, by the triangle inequality, none of
+	// its items can be within tau of the target. This is meant for
+	// visualizing searches, e.g. for teaching. The coarse index, if any, is
+	// not used for such searches.
+	OnPruneDecision func(nodeItem interface{}, dist, tau, threshold float64, visitedLeft, visitedRight bool)
+
+	// Less, if set, ranks neighbours at the same distance from the target,
+	// e.g. by freshness and then by id: it reports whether a ranks before
+	// b. If there are more than K neighbours at the distance of the K-th
+	// result, the ones that rank first are returned, and results at the
+	// same distance are returned in the order of Less. Searches always rank
+	// by distance first, since pruning relies on it, so Less is only called
+	// for neighbours at the same distance; a comparator that compares
+	// distances first can be passed as it is. SearchTopThenRest ignores
+	// Less.
+	Less func(a, b Neighbor) bool
+}
+
+// SearchStats describes the work done by a single search.
+type SearchStats struct {
+	// NodesVisited is the number of tree nodes the search descended into.
+	NodesVisited int
+
+	// MetricCalls is the number of times the distance metric was evaluated.
+	MetricCalls int
+
+	// MinPrunedDistance is a lower bound on the distance from the target to
+	// any item the search skipped, either by pruning or because of MaxNodes,
+	// or +Inf if it skipped nothing. If it exceeds the distance of the k-th
+	// result, no skipped item could have been among the results, so they are
+	// exact.
+	MinPrunedDistance float64
+}
+
 // Search searches the VP-tree for the k nearest neighbours of target. It
 // returns the up to k narest neighbours and the corresponding distances in
 // order of least distance to largest distance.
 func (vp *VPTree) Search(target interface{}, k int) (results []interface{}, distances []float64) {
-	if k < 1 {
+	return vp.SearchWithParameters(target, SearchParameters{K: k})
+}
+
+// SearchWithParameters is like Search, but takes its options from p.
+func (vp *VPTree) SearchWithParameters(target interface{}, p SearchParameters) (results []interface{}, distances []float64) {
+	results, distances, _ = vp.SearchWithStats(target, p)
+	return
+}
+
+// SearchWithStats is like SearchWithParameters, but additionally reports how
+// much work the search did.
+func (vp *VPTree) SearchWithStats(target interface{}, p SearchParameters) (results []interface{}, distances []float64, stats SearchStats) {
+	if p.K < 1 {
 		return
 	}
 
-	h := make(priorityQueue, 0, k)
+	s := vp.newSearcher(target, p)
+	s.run()
+	results, distances = s.results()
 
-	tau := math.MaxFloat64
-	vp.search(vp.root, &tau, target, k, &h)
+	return results, distances, s.stats
+}
 
-	for h.Len() > 0 {
-		hi := heap.Pop(&h)
-		results = append(results, hi.(*heapItem).Item)
-		distances = append(distances, hi.(*heapItem).Dist)
+// SearchDistances is like SearchWithParameters, but only returns the
+// distances of the nearest neighbours, which avoids resolving the items
+// themselves. This is useful when only the scores of the results matter.
+func (vp *VPTree) SearchDistances(target interface{}, p SearchParameters) (distances []float64) {
+	if p.K < 1 {
+		return
 	}
 
-	// Reverse results and distances, because we popped them from the heap
-	// in large-to-small order
-	for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
-		results[i], results[j] = results[j], results[i]
-		distances[i], distances[j] = distances[j], distances[i]
+	s := vp.newSearcher(target, p)
+	s.run()
+
+	items, distances := s.expand(s.drain())
+	if p.OnResult != nil {
+		for i := range distances {
+			p.OnResult(s.target, s.resolve(items[i]), distances[i], i)
+		}
+	}
+
+	return
+}
+
+// Items returns all items stored in the VP-tree, in no particular order.
+func (vp *VPTree) Items() []interface{} {
+	items := make([]interface{}, 0, vp.size)
+	vp.root.walk(func(n *node) {
+		items = append(items, n.Item)
+	})
+	return items
+}
+
+// walk calls fn for every node of the subtree rooted at n in pre-order.
+func (n *node) walk(fn func(n *node)) {
+	if n == nil {
+		return
 	}
+	fn(n)
+	n.Left.walk(fn)
+	n.Right.walk(fn)
+	for _, b := range n.Bucket {
+		b.walk(fn)
+	}
+}
 
+func (vp *VPTree) buildFromPoints(items []interface{}, depth int) (n *node) {
+	n, left, right := vp.buildNode(items, depth)
+	if n != nil {
+		n.Left = vp.buildFromPoints(left, depth+1)
+		n.Right = vp.buildFromPoints(right, depth+1)
+	}
 	return
 }
 
-func (vp *VPTree) buildFromPoints(items []interface{}) (n *node) {
+// buildNode chooses a vantage point from items and partitions the remaining
+// items around it. It returns the new node, whose subtrees are yet to be
+// built from the left and right items, or nil if there are no items.
+func (vp *VPTree) buildNode(items []interface{}, depth int) (n *node, left, right []interface{}) {
 	if len(items) == 0 {
-		return nil
+		return nil, nil, nil
+	}
+
+	var start time.Time
+	if vp.buildStats != nil {
+		start = time.Now()
 	}
 
-	n = &node{}
+	n = vp.newNode()
+	n.Size = len(items)
 
-	// Take a random item out of the items slice and make it this node's item
-	idx := rand.Intn(len(items))
+	// Take an item out of the items slice and make it this node's item
+	idx := vp.selectVantage(items)
 	n.Item = items[idx]
 	items[idx], items = items[len(items)-1], items[:len(items)-1]
 
+	var median int
 	if len(items) > 0 {
-		// Now partition the items into two equal-sized sets, one
-		// closer to the node's item than the median, and one farther
-		// away.
-		median := len(items) / 2
-		pivotDist := vp.distanceMetric(items[median], n.Item)
-		items[median], items[len(items)-1] = items[len(items)-1], items[median]
-
-		storeIndex := 0
-		for i := 0; i < len(items)-1; i++ {
-			if vp.distanceMetric(items[i], n.Item) <= pivotDist {
-				items[storeIndex], items[i] = items[i], items[storeIndex]
-				storeIndex++
+		if vp.balanceFactor > 0 {
+			median = vp.partitionBalanced(n, items)
+		} else {
+			median = vp.partition(n, items)
+		}
+		median = vp.quantizeSplit(n, items, median)
+	}
+
+	if vp.buildStats != nil {
+		vp.buildStats.addLevelTime(depth, time.Since(start))
+	}
+	if vp.onBuildEvent != nil {
+		vp.onBuildEvent(BuildEvent{depth, n.Item, n.Size, n.Threshold, median, len(items) - median})
+	}
+
+	return n, items[:median], items[median:]
+}
+
+// newNode returns a new node, taken from the arena if there is one left.
+func (vp *VPTree) newNode() *node {
+	if len(vp.arena) == 0 {
+		return &node{}
+	}
+
+	n := &vp.arena[0]
+	vp.arena = vp.arena[1:]
+	return n
+}
+
+// partition partitions the items into two equal-sized sets, one closer to the
+// vantage point of n than the median, and one farther away. It sets the
+// threshold and radii of n and returns the index of the first item of the
+// farther set.
+func (vp *VPTree) partition(n *node, items []interface{}) (median int) {
+	median = len(items) / 2
+	pivotDist := vp.distanceMetric(items[median], n.Item)
+	items[median], items[len(items)-1] = items[len(items)-1], items[median]
+
+	n.Threshold = pivotDist
+	n.LeftRadius, n.RightRadius = 0, pivotDist
+
+	storeIndex := 0
+	for i := 0; i < len(items)-1; i++ {
+		dist := vp.distanceMetric(items[i], n.Item)
+		if dist <= pivotDist {
+			items[storeIndex], items[i] = items[i], items[storeIndex]
+			storeIndex++
+			n.LeftRadius = math.Max(n.LeftRadius, dist)
+		} else {
+			n.RightRadius = math.Max(n.RightRadius, dist)
+		}
+	}
+	items[len(items)-1], items[storeIndex] = items[storeIndex], items[len(items)-1]
+
+	return storeIndex
+}
+
+// partitionBalanced sorts items by their distance to the vantage point of n
+// and picks a split index so that the two halves respect vp.balanceFactor.
+// Among the admissible split indices it prefers the one closest to the middle
+// that does not separate equidistant items; if there is none, it splits at
+// the middle. Like partition, it sets the threshold and radii of n.
+func (vp *VPTree) partitionBalanced(n *node, items []interface{}) (split int) {
+	byDist := &itemsByDistance{
+		items: items,
+		dists: make([]float64, len(items)),
+	}
+	for i, item := range items {
+		byDist.dists[i] = vp.distanceMetric(item, n.Item)
+	}
+	sort.Sort(byDist)
+
+	size := len(items)
+	split = size / 2
+
+search:
+	for offset := 0; offset <= size/2; offset++ {
+		for _, s := range []int{size/2 - offset, size/2 + offset} {
+			if s <= 0 || s >= size || !vp.balanced(s, size-s) {
+				continue
+			}
+			if byDist.dists[s-1] < byDist.dists[s] {
+				split = s
+				break search
 			}
 		}
-		items[len(items)-1], items[storeIndex] = items[storeIndex], items[len(items)-1]
-		median = storeIndex
+	}
 
-		n.Threshold = pivotDist
-		n.Left = vp.buildFromPoints(items[:median])
-		n.Right = vp.buildFromPoints(items[median:])
+	n.Threshold = byDist.dists[split]
+	n.RightRadius = byDist.dists[size-1]
+	if split > 0 {
+		n.LeftRadius = byDist.dists[split-1]
 	}
-	return
+
+	return split
+}
+
+// balanced reports whether two sibling subtrees of the given sizes satisfy
+// vp.balanceFactor.
+func (vp *VPTree) balanced(a, b int) bool {
+	if a > b {
+		a, b = b, a
+	}
+	return b-a <= 1 || float64(b) <= vp.balanceFactor*float64(a)
+}
+
+// itemsByDistance sorts items by their precomputed distances.
+type itemsByDistance struct {
+	items []interface{}
+	dists []float64
+}
+
+func (s *itemsByDistance) Len() int { return len(s.items) }
+
+func (s *itemsByDistance) Less(i, j int) bool { return s.dists[i] < s.dists[j] }
+
+func (s *itemsByDistance) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.dists[i], s.dists[j] = s.dists[j], s.dists[i]
+}
+
+// A searcher holds the state of a single k-nearest-neighbour search.
+type searcher struct {
+	vp     *VPTree
+	p      SearchParameters
+	target interface{}
+	k      int
+	tau    float64
+	h      priorityQueue
+	stats  SearchStats
+
+	// skip is a node whose item must not be returned, e.g. because the
+	// target is that very item.
+	skip *node
+
+	// expanded is the number of visited nodes that have children.
+	expanded int
+
+	// truncated is set if MaxNodes or MaxSubtrees stopped the search from
+	// visiting a node.
+	truncated bool
+
+	// depth is the depth in the tree of the node being visited.
+	depth int
+
+	// entered is the number of subtrees at depth p.SubtreeDepth that the
+	// search has descended into so far, which MaxSubtrees limits.
+	entered int
+
+	// nearest is the distance of the nearest result so far, which was found
+	// at the nearestAt-th visited node.
+	nearest   float64
+	nearestAt int
+
+	// If subtrees is set, it records for every added node which subtree of
+	// the root it is in, which is branch at the time; see SearchWithSubtrees.
+	subtrees map[*node]int
+	branch   int
+
+	// If depths is set, it records the depth of every added node, which is
+	// depth at the time; see SearchWithDepths.
+	depths map[*node]int
 }
 
-func (vp *VPTree) search(n *node, tau *float64, target interface{}, k int, h *priorityQueue) {
+func (vp *VPTree) newSearcher(target interface{}, p SearchParameters) *searcher {
+	if p.QuantizeTarget != nil {
+		target = p.QuantizeTarget(target)
+	}
+
+	s := &searcher{
+		vp:      vp,
+		p:       p,
+		target:  target,
+		k:       p.K,
+		tau:     math.MaxFloat64,
+		nearest: math.Inf(1),
+		h:       make(priorityQueue, 0, p.K),
+	}
+	s.stats.MinPrunedDistance = math.Inf(1)
+
+	if p.MaxDistance > 0 {
+		s.tau = p.MaxDistance
+	}
+
+	return s
+}
+
+// run searches the whole VP-tree, using the coarse index if there is one.
+// Searches with MaxNodes, MaxSubtrees or OnPruneDecision do not use it, since
+// it measures all leaders up front rather than visiting them as nodes.
+func (s *searcher) run() {
+	if s.vp.coarse != nil && s.p.MaxNodes <= 0 && s.p.MaxSubtrees <= 0 && s.p.OnPruneDecision == nil {
+		s.searchCoarse(s.vp.coarse)
+		return
+	}
+	s.search(s.vp.root, 0)
+}
+
+// search searches the subtree rooted at n, none of whose items is closer to
+// the target than lower.
+func (s *searcher) search(n *node, lower float64) {
 	if n == nil {
 		return
 	}
 
-	dist := vp.distanceMetric(n.Item, target)
+	if s.p.MaxNodes > 0 && s.stats.NodesVisited >= s.p.MaxNodes {
+		s.truncated = true
+		s.prune(lower)
+		return
+	}
+
+	s.stats.NodesVisited++
+	s.stats.MetricCalls++
+	dist := s.vp.distanceMetric(n.Item, s.target)
 
-	if dist < *tau {
-		if h.Len() == k {
-			heap.Pop(h)
-		}
-		heap.Push(h, &heapItem{n.Item, dist})
-		if h.Len() == k {
-			*tau = h.Top().(*heapItem).Dist
-		}
+	s.add(n, dist)
+
+	// The nodes of a bucket are one level below n
+	s.depth++
+	for _, b := range n.Bucket {
+		s.search(b, lower)
 	}
+	s.depth--
 
 	if n.Left == nil && n.Right == nil {
 		return
 	}
+	s.expanded++
+
+	// By the triangle inequality, no item in a subtree is closer to the
+	// target than these bounds.
+	leftLower := math.Max(lower, dist-math.Min(s.vp.leftBound(n), n.LeftRadius))
+	rightLower := math.Max(lower, math.Max(n.Threshold-dist, dist-n.RightRadius))
 
+	// tau is the search radius the decision about the second subtree is
+	// based on, for OnPruneDecision
+	var visitedLeft, visitedRight bool
+	var tau float64
+	s.depth++
 	if dist < n.Threshold {
-		if dist-*tau <= n.Threshold {
-			vp.search(n.Left, tau, target, k, h)
+		visitedLeft = s.visit(n.Left, leftLower)
+		tau = s.tau
+		visitedRight = s.visit(n.Right, rightLower)
+	} else {
+		visitedRight = s.visit(n.Right, rightLower)
+		tau = s.tau
+		visitedLeft = s.visit(n.Left, leftLower)
+	}
+	s.depth--
+
+	if s.p.OnPruneDecision != nil {
+		s.p.OnPruneDecision(n.Item, dist, tau, n.Threshold, visitedLeft, visitedRight)
+	}
+}
+
+// visit searches the subtree rooted at n, unless it is pruned because no item
+// in it is closer to the target than lower, and reports whether it searched
+// it.
+func (s *searcher) visit(n *node, lower float64) bool {
+	if n == nil {
+		return false
+	}
+
+	if lower > s.tau {
+		s.prune(lower)
+		return false
+	}
+
+	if s.p.MaxSubtrees > 0 && s.depth == max(s.p.SubtreeDepth, 1) {
+		if s.entered >= s.p.MaxSubtrees {
+			s.truncated = true
+			s.prune(lower)
+			return false
 		}
+		s.entered++
+	}
 
-		if dist+*tau >= n.Threshold {
-			vp.search(n.Right, tau, target, k, h)
+	if s.subtrees != nil {
+		switch n {
+		case s.vp.root.Left:
+			s.branch = SubtreeLeft
+		case s.vp.root.Right:
+			s.branch = SubtreeRight
 		}
-	} else {
-		if dist+*tau >= n.Threshold {
-			vp.search(n.Right, tau, target, k, h)
+	}
+
+	s.search(n, lower)
+	return true
+}
+
+// prune records that a subtree whose items are at least lower away from the
+// target was skipped.
+func (s *searcher) prune(lower float64) {
+	s.stats.MinPrunedDistance = math.Min(s.stats.MinPrunedDistance, lower)
+}
+
+// add adds the item of n, which is dist away from the target, to the results
+// found so far if it belongs there, and reports whether it did.
+func (s *searcher) add(n *node, dist float64) bool {
+	if !s.accepts(n, dist) {
+		return false
+	}
+
+	if s.h.Len() == s.k {
+		heap.Pop(s.queue())
+	}
+	heap.Push(s.queue(), &heapItem{n.Item, dist, n})
+	if s.subtrees != nil {
+		s.subtrees[n] = s.branch
+	}
+	if s.depths != nil {
+		s.depths[n] = s.depth
+	}
+	if s.h.Len() == s.k {
+		s.tau = s.h.Top().(*heapItem).Dist
+	}
+	if dist < s.nearest {
+		s.nearest, s.nearestAt = dist, s.stats.NodesVisited
+	}
+
+	return true
+}
+
+// accepts reports whether the item of n, which is dist away from the target,
+// belongs in the results found so far.
+func (s *searcher) accepts(n *node, dist float64) bool {
+	// Until the heap is full, tau is an inclusive bound given by
+	// MaxDistance; afterwards we only accept strictly closer items, or
+	// items at the same distance that Less ranks before the last one.
+	if !(dist < s.tau || (dist == s.tau && (s.h.Len() < s.k || s.ranksBeforeLast(n.Item, dist)))) {
+		return false
+	}
+
+	return n != s.skip && (s.p.Exclude == nil || !s.p.Exclude(n.Item))
+}
+
+// ranksBeforeLast reports whether Less ranks item, which is dist away from the
+// target, before the last of the results found so far.
+func (s *searcher) ranksBeforeLast(item interface{}, dist float64) bool {
+	if s.p.Less == nil {
+		return false
+	}
+	last := s.h.Top().(*heapItem)
+	return s.p.Less(Neighbor{item, dist}, Neighbor{last.Item, last.Dist})
+}
+
+// queue returns the searcher's heap, ordered by Less if it is set.
+func (s *searcher) queue() heap.Interface {
+	if s.p.Less == nil {
+		return &s.h
+	}
+	return rankedQueue{&s.h, s.p.Less}
+}
+
+// resolve applies the IDLookup and PayloadResolver, if any, to item.
+func (s *searcher) resolve(item interface{}) interface{} {
+	if s.p.IDLookup != nil {
+		item = lookupID(s.p.IDLookup, item)
+	}
+	if s.p.PayloadResolver == nil {
+		return item
+	}
+	return s.p.PayloadResolver(item)
+}
+
+// expand returns the items and distances of hits, which are in order of least
+// distance to largest distance, with every item replaced by its duplicates if
+// ExpandDuplicates is set, and at most K of them.
+func (s *searcher) expand(hits []*heapItem) (items []interface{}, distances []float64) {
+	for _, hi := range hits {
+		group := []interface{}{hi.Item}
+		if s.p.ExpandDuplicates && s.vp.dups != nil {
+			if g := s.vp.dups.lookup(hi.Item); g != nil {
+				group = g
+			}
 		}
 
-		if dist-*tau <= n.Threshold {
-			vp.search(n.Left, tau, target, k, h)
+		for _, item := range group {
+			if len(items) == s.p.K {
+				return
+			}
+			items = append(items, item)
+			distances = append(distances, hi.Dist)
 		}
 	}
+
+	return
+}
+
+// lookupID returns ids[index], or nil if index is not an int or out of range.
+func lookupID(ids []interface{}, index interface{}) interface{} {
+	i, ok := index.(int)
+	if !ok || i < 0 || i >= len(ids) {
+		return nil
+	}
+	return ids[i]
+}
+
+// drain empties the searcher's heap and returns its contents in order of least
+// distance to largest distance.
+func (s *searcher) drain() []*heapItem {
+	items := make([]*heapItem, s.h.Len())
+	for i := len(items) - 1; i >= 0; i-- {
+		items[i] = heap.Pop(s.queue()).(*heapItem)
+	}
+	return items
+}
+
+// results drains the searcher's heap and returns the items found and their
+// distances in order of least distance to largest distance.
+func (s *searcher) results() (results []interface{}, distances []float64) {
+	return s.collect(s.drain())
+}
+
+// collect returns the resolved items and distances of hits, which are in order
+// of least distance to largest distance.
+func (s *searcher) collect(hits []*heapItem) (results []interface{}, distances []float64) {
+	results, distances = s.expand(hits)
+	for i := range results {
+		results[i] = s.resolve(results[i])
+	}
+
+	if s.p.OnResult != nil {
+		for i := range results {
+			s.p.OnResult(s.target, results[i], distances[i], i)
+		}
+	}
+
+	return
 }
@@ -2,8 +2,11 @@ package vptree
 
 import (
 	"container/heap"
+	"fmt"
 	"math"
 	"math/rand"
+	"reflect"
+	"sort"
 	"sync"
 	"testing"
 )
@@ -49,7 +52,7 @@ func nearestNeighbours(target Coordinate, items []Coordinate, k int) (coords []C
 
 	// Push all items onto a heap
 	for _, v := range items {
-		heap.Push(pq, &heapItem{v, CoordinateMetric(v, target)})
+		heap.Push(pq, &heapItem{Item: v, Dist: CoordinateMetric(v, target)})
 	}
 
 	// Pop all but the k smallest items
@@ -184,3 +187,344 @@ func TestConcurrent(t *testing.T) {
 
 	wg.Wait()
 }
+
+// This test makes sure MaxDistance limits the search radius
+func TestMaxDistance(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 1000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	coords2, distances2 := nearestNeighbours(q, items, 50)
+
+	// Cut the expected results off at the distance of the 20th neighbour
+	maxDist := distances2[19]
+	coords1, distances1 := vp.SearchWithParameters(q, SearchParameters{K: 50, MaxDistance: maxDist})
+
+	compareCoordDistSets(t, coords1, coords2[:20], distances1, distances2[:20])
+}
+
+// This test makes sure SearchDistances returns the same distances as Search
+func TestSearchDistances(t *testing.T) {
+	vpitems := make([]interface{}, 1000)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	for i := 0; i < 10; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		p := SearchParameters{K: rand.Intn(50) + 1}
+
+		_, expected := vp.SearchWithParameters(q, p)
+		actual := vp.SearchDistances(q, p)
+
+		if len(actual) != len(expected) {
+			t.Fatalf("Expected %v distances, got %v", len(expected), len(actual))
+		}
+		for j := range actual {
+			if actual[j] != expected[j] {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, expected[j], actual[j])
+			}
+		}
+	}
+
+	if d := vp.SearchDistances(Coordinate{}, SearchParameters{}); len(d) != 0 {
+		t.Errorf("Expected no distances for K = 0, got %v", d)
+	}
+
+	// OnResult sees the same calls in the same order as from
+	// SearchWithParameters, including the quantized target
+	type call struct {
+		target, result interface{}
+		dist           float64
+		rank           int
+	}
+	var calls [2][]call
+	for i, search := range []func(q interface{}, p SearchParameters){
+		func(q interface{}, p SearchParameters) { vp.SearchWithParameters(q, p) },
+		func(q interface{}, p SearchParameters) { vp.SearchDistances(q, p) },
+	} {
+		search(Coordinate{0.501, 0.499}, SearchParameters{
+			K: 5,
+			OnResult: func(target, result interface{}, dist float64, rank int) {
+				calls[i] = append(calls[i], call{target, result, dist, rank})
+			},
+			QuantizeTarget: func(target interface{}) interface{} {
+				return Coordinate{0.5, 0.5}
+			},
+		})
+	}
+	if len(calls[1]) != 5 || !reflect.DeepEqual(calls[0], calls[1]) {
+		t.Errorf("Expected OnResult calls %v, got %v", calls[0], calls[1])
+	}
+	for i, c := range calls[1] {
+		if c.rank != i || c.target != (Coordinate{0.5, 0.5}) {
+			t.Errorf("Expected call %v for rank %v with the quantized target, got %v", i, i, c)
+		}
+	}
+}
+
+// This test makes sure the OnResult hook fires once per returned result
+func TestOnResult(t *testing.T) {
+	vpitems := make([]interface{}, 500)
+	for i := range vpitems {
+		vpitems[i] = Coordinate{X: rand.Float64(), Y: rand.Float64()}
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+
+	var hookResults []interface{}
+	var hookDists []float64
+	p := SearchParameters{
+		K: 10,
+		OnResult: func(target, result interface{}, dist float64, rank int) {
+			if target != q {
+				t.Errorf("Expected target %v, got %v", q, target)
+			}
+			if rank != len(hookResults) {
+				t.Errorf("Expected rank %v, got %v", len(hookResults), rank)
+			}
+			hookResults = append(hookResults, result)
+			hookDists = append(hookDists, dist)
+		},
+	}
+
+	results, distances := vp.SearchWithParameters(q, p)
+
+	if len(hookResults) != len(results) {
+		t.Fatalf("Expected hook to fire %v times, got %v", len(results), len(hookResults))
+	}
+	for i := range results {
+		if hookResults[i] != results[i] || hookDists[i] != distances[i] {
+			t.Errorf("Expected hook call %v to be (%v, %v), got (%v, %v)", i, results[i], distances[i], hookResults[i], hookDists[i])
+		}
+	}
+}
+
+// This test makes sure the PayloadResolver hydrates the returned keys
+func TestPayloadResolver(t *testing.T) {
+	payloads := make(map[int]string)
+	points := make(map[int]float64)
+	vpitems := make([]interface{}, 100)
+	for i := range vpitems {
+		vpitems[i] = i
+		points[i] = float64(i * i)
+		payloads[i] = fmt.Sprintf("payload %v", i)
+	}
+
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(points[a.(int)] - points[b.(int)])
+	}
+	vp := New(metric, vpitems)
+
+	raw, rawDists := vp.SearchWithParameters(42, SearchParameters{K: 3})
+
+	resolved := 0
+	hydrated, dists := vp.SearchWithParameters(42, SearchParameters{
+		K: 3,
+		PayloadResolver: func(key interface{}) interface{} {
+			resolved++
+			return payloads[key.(int)]
+		},
+	})
+
+	if resolved != 3 {
+		t.Errorf("Expected the resolver to be called 3 times, got %v", resolved)
+	}
+
+	for i := range raw {
+		if raw[i] != 42+[]int{0, -1, 1}[i] {
+			t.Errorf("Expected raw result %v to be a key, got %v", i, raw[i])
+		}
+		if hydrated[i] != payloads[raw[i].(int)] {
+			t.Errorf("Expected result %v to be %q, got %v", i, payloads[raw[i].(int)], hydrated[i])
+		}
+		if dists[i] != rawDists[i] {
+			t.Errorf("Expected distance %v, got %v", rawDists[i], dists[i])
+		}
+	}
+}
+
+// This test makes sure MinPrunedDistance certifies exact results and bounds
+// the error of truncated searches
+func TestMinPrunedDistance(t *testing.T) {
+	var items []Coordinate
+	for i := 0; i < 2000; i++ {
+		items = append(items, Coordinate{X: rand.Float64(), Y: rand.Float64()})
+	}
+
+	vpitems := make([]interface{}, len(items))
+	for i, v := range items {
+		vpitems[i] = interface{}(v)
+	}
+	vp := New(CoordinateMetric, vpitems)
+
+	for i := 0; i < 20; i++ {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		_, expectedDists := nearestNeighbours(q, items, 10)
+
+		_, distances, stats := vp.SearchWithStats(q, SearchParameters{K: 10})
+		if stats.MinPrunedDistance <= distances[len(distances)-1] {
+			t.Errorf("Expected MinPrunedDistance to exceed %v, got %v", distances[len(distances)-1], stats.MinPrunedDistance)
+		}
+
+		// All true neighbours closer than MinPrunedDistance must be found
+		_, distances, stats = vp.SearchWithStats(q, SearchParameters{K: 10, MaxNodes: 30})
+		for j, d := range expectedDists {
+			if d >= stats.MinPrunedDistance {
+				break
+			}
+			if distances[j] != d {
+				t.Errorf("Expected distances[%v] to be %v, got %v", j, d, distances[j])
+			}
+		}
+	}
+
+	_, _, stats := vp.SearchWithStats(Coordinate{}, SearchParameters{K: 2000})
+	if !math.IsInf(stats.MinPrunedDistance, 1) {
+		t.Errorf("Expected MinPrunedDistance to be +Inf when nothing was pruned, got %v", stats.MinPrunedDistance)
+	}
+}
+
+// This test makes sure OnPruneDecision reports the values that decided which
+// subtrees to search, on a small tree built by hand
+func TestOnPruneDecision(t *testing.T) {
+	vp := newVPTree(absMetric, 3)
+	vp.root = &node{
+		Item:        5.0,
+		Threshold:   3,
+		LeftRadius:  2,
+		RightRadius: 5,
+		Size:        3,
+		Left:        &node{Item: 3.0, Size: 1},
+		Right:       &node{Item: 10.0, Size: 1},
+	}
+
+	type decision struct {
+		item                      interface{}
+		dist, tau, threshold      float64
+		visitedLeft, visitedRight bool
+	}
+
+	for _, test := range []struct {
+		q        float64
+		k        int
+		expected decision
+	}{
+		// The right subtree is at least 3-0.5 away, farther than 5 itself
+		{4.5, 1, decision{5.0, 0.5, 0.5, 3, true, false}},
+		// 10 is found first, and the left subtree is at least 4-2 away
+		{9, 1, decision{5.0, 4, 1, 3, false, true}},
+		// Nothing is pruned until the heap is full
+		{4.5, 3, decision{5.0, 0.5, math.MaxFloat64, 3, true, true}},
+	} {
+		var decisions []decision
+		vp.SearchWithParameters(test.q, SearchParameters{
+			K: test.k,
+			OnPruneDecision: func(nodeItem interface{}, dist, tau, threshold float64, visitedLeft, visitedRight bool) {
+				decisions = append(decisions, decision{nodeItem, dist, tau, threshold, visitedLeft, visitedRight})
+			},
+		})
+
+		if len(decisions) != 1 || decisions[0] != test.expected {
+			t.Errorf("query %v, k %v: expected %+v, got %+v", test.q, test.k, test.expected, decisions)
+		}
+	}
+}
+
+// This test makes sure IDLookup maps the indices a tree holds to the ids they
+// stand for, and copes with items that are not valid indices
+func TestIDLookup(t *testing.T) {
+	points := []float64{0, 10, 20, 30, 40}
+	ids := []interface{}{"a", "b", "c", "d"}
+
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(points[a.(int)] - points[b.(int)])
+	}
+	items := make([]interface{}, len(points))
+	for i := range items {
+		items[i] = i
+	}
+	vp := New(metric, items)
+
+	results, distances := vp.SearchWithParameters(1, SearchParameters{K: 2, IDLookup: ids})
+	if len(results) != 2 || results[0] != "b" || distances[1] != 10 {
+		t.Errorf("Expected b and a neighbour 10 away, got %v at %v", results, distances)
+	}
+
+	// Index 4 has no id
+	results, _ = vp.SearchWithParameters(4, SearchParameters{
+		K:               2,
+		IDLookup:        ids,
+		PayloadResolver: func(id interface{}) interface{} { return []interface{}{id} },
+	})
+	if len(results) != 2 || results[0].([]interface{})[0] != nil || results[1].([]interface{})[0] != "d" {
+		t.Errorf("Expected no id for index 4, then d, got %v", results)
+	}
+}
+
+// This test makes sure Less picks and orders the results among neighbours at
+// the same distance, without changing which distances are returned
+func TestSearchLess(t *testing.T) {
+	type version struct {
+		X, Age, ID int
+	}
+	metric := func(a, b interface{}) float64 {
+		return math.Abs(float64(a.(version).X - b.(version).X))
+	}
+
+	var items []interface{}
+	for x := 0; x < 20; x++ {
+		for i := 0; i < 5; i++ {
+			items = append(items, version{x, rand.Intn(3), len(items)})
+		}
+	}
+	vp := New(metric, append([]interface{}(nil), items...))
+
+	// Distance, then freshness, then id
+	less := func(a, b Neighbor) bool {
+		va, vb := a.Item.(version), b.Item.(version)
+		switch {
+		case a.Distance != b.Distance:
+			return a.Distance < b.Distance
+		case va.Age != vb.Age:
+			return va.Age < vb.Age
+		default:
+			return va.ID < vb.ID
+		}
+	}
+
+	for _, k := range []int{1, 3, 7, 12, 23} {
+		q := version{X: rand.Intn(20)}
+
+		// The expected results, by brute force
+		expected := make([]Neighbor, len(items))
+		for i, item := range items {
+			expected[i] = Neighbor{item, metric(item, q)}
+		}
+		sort.Slice(expected, func(i, j int) bool { return less(expected[i], expected[j]) })
+
+		results, distances := vp.SearchWithParameters(q, SearchParameters{K: k, Less: less})
+		if len(results) != k {
+			t.Fatalf("k %v: expected %v results, got %v", k, k, len(results))
+		}
+		for i := range results {
+			if results[i] != expected[i].Item || distances[i] != expected[i].Distance {
+				t.Errorf("k %v: expected result %v to be %v, got %v at %v", k, i, expected[i], results[i], distances[i])
+			}
+		}
+
+		if plain := vp.SearchDistances(q, SearchParameters{K: k, Less: less}); !reflect.DeepEqual(plain, distances) {
+			t.Errorf("k %v: expected distances %v, got %v", k, distances, plain)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package vptree
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// EncodeResults encodes search results into a compact binary format, e.g. to
+// send them over the network, using enc to encode the items. The format is
+// the number of results as a uvarint, followed by every result in order: its
+// distance as a little-endian float64, and its encoded item, prefixed with
+// the item's length as a uvarint. DecodeResults decodes it.
+func EncodeResults(results []interface{}, distances []float64, enc func(interface{}) []byte) []byte {
+	buf := binary.AppendUvarint(nil, uint64(len(results)))
+	for i, item := range results {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(distances[i]))
+
+		data := enc(item)
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		buf = append(buf, data...)
+	}
+	return buf
+}
+
+// errResultsCorrupt is returned by DecodeResults for malformed input.
+var errResultsCorrupt = errors.New("vptree: encoded results are truncated or corrupt")
+
+// DecodeResults decodes search results encoded by EncodeResults, using dec to
+// decode the items. It returns an error if data is malformed or dec fails.
+func DecodeResults(data []byte, dec func([]byte) (interface{}, error)) (results []interface{}, distances []float64, err error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, nil, errResultsCorrupt
+	}
+	data = data[n:]
+
+	// Every result takes at least 9 bytes, which bounds the allocation for
+	// corrupt counts
+	if count > uint64(len(data))/9 {
+		return nil, nil, errResultsCorrupt
+	}
+
+	results = make([]interface{}, 0, count)
+	distances = make([]float64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		if len(data) < 8 {
+			return nil, nil, errResultsCorrupt
+		}
+		dist := math.Float64frombits(binary.LittleEndian.Uint64(data))
+		data = data[8:]
+
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return nil, nil, errResultsCorrupt
+		}
+		data = data[n:]
+
+		item, err := dec(data[:size:size])
+		if err != nil {
+			return nil, nil, err
+		}
+		data = data[size:]
+
+		results = append(results, item)
+		distances = append(distances, dist)
+	}
+
+	if len(data) != 0 {
+		return nil, nil, errResultsCorrupt
+	}
+
+	return results, distances, nil
+}
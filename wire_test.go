@@ -0,0 +1,44 @@
+package vptree
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func encodeRecord(item interface{}) []byte {
+	record := make([]byte, 16)
+	encodeCoordinate(item, record)
+	return record
+}
+
+func decodeRecord(data []byte) (interface{}, error) {
+	if len(data) != 16 {
+		return nil, errors.New("invalid record")
+	}
+	return decodeCoordinate(data), nil
+}
+
+// This test makes sure search results survive encoding and decoding
+func TestEncodeResults(t *testing.T) {
+	vp := newRandomCoordinateTree(1000)
+
+	for _, k := range []int{0, 1, 10} {
+		q := Coordinate{X: rand.Float64(), Y: rand.Float64()}
+		expected, expectedDists := vp.Search(q, k)
+
+		data := EncodeResults(expected, expectedDists, encodeRecord)
+		results, distances, err := DecodeResults(data, decodeRecord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareResults(t, results, distances, expected, expectedDists)
+
+		// Truncated input must be rejected
+		for n := 0; n < len(data); n++ {
+			if _, _, err := DecodeResults(data[:n], decodeRecord); err == nil {
+				t.Fatalf("Expected an error for %v of %v bytes", n, len(data))
+			}
+		}
+	}
+}